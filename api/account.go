@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/google/uuid"
+)
+
+type sessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newSessionResponse leaves out the refresh token of the session
+func newSessionResponse(session db.Session) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+	}
+}
+
+type exportUserResponse struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	User       userResponse      `json:"user"`
+	Projects   []db.Project      `json:"projects"`
+	Tags       []db.Tag          `json:"tags"`
+	Tasks      []db.Task         `json:"tasks"`
+	TaskTags   []db.TaskTag      `json:"task_tags"`
+	Sessions   []sessionResponse `json:"sessions"`
+}
+
+// exportUser returns all the data of the authenticated user as a JSON archive
+func (server *Server) exportUser(w http.ResponseWriter, r *http.Request) {
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	result, err := server.store.ExportUserTx(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := exportUserResponse{
+		ExportedAt: time.Now(),
+		User:       newUserResponse(result.User),
+		Projects:   result.Projects,
+		Tags:       result.Tags,
+		Tasks:      result.Tasks,
+		TaskTags:   result.TaskTags,
+		Sessions:   make([]sessionResponse, 0, len(result.Sessions)),
+	}
+	for _, session := range result.Sessions {
+		res.Sessions = append(res.Sessions, newSessionResponse(session))
+	}
+
+	filename := fmt.Sprintf("devoratasks-%s.json", result.User.Username)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+type deleteUserRequest struct {
+	Password string `json:"password" requirements:"required"`
+}
+
+// deleteUser removes the authenticated user and all its data, the password is
+// asked again so a stolen access token isn't enough to delete the account
+func (server *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	var req deleteUserRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	user, err := server.store.GetUser(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = util.CheckPassword(req.Password, user.HashedPassword)
+	if err != nil {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeInvalidCredentials, "incorrect password"))
+		return
+	}
+
+	err = server.store.DeleteUserTx(r.Context(), user.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), user.Username, time.Now())
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.Revoke(r.Context(), payload.ID, payload.ExpiresAt)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
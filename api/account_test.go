@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+	tag := randomTag(user.Username)
+	task := randomTask(user.Username)
+	payload, err := token.NewPayload(user.Username, util.UserRole, time.Hour, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+	session := randomSession(payload, util.RandomPassword(32))
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ExportUserTx(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.ExportUserTxResult{
+						User:     user,
+						Projects: []db.Project{project},
+						Tags:     []db.Tag{tag},
+						Tasks:    []db.Task{task},
+						TaskTags: []db.TaskTag{{TaskID: task.ID, TagID: tag.ID}},
+						Sessions: []db.Session{session},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+				// refresh tokens and password hashes are never exported
+				require.NotContains(t, recorder.Body.String(), session.RefreshToken)
+				require.NotContains(t, recorder.Body.String(), user.HashedPassword)
+
+				var res exportUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.WithinDuration(t, time.Now(), res.ExportedAt, time.Second)
+				require.Equal(t, user.Username, res.User.Username)
+				require.Len(t, res.Projects, 1)
+				require.Equal(t, project.ID, res.Projects[0].ID)
+				require.Len(t, res.Tags, 1)
+				require.Equal(t, tag.ID, res.Tags[0].ID)
+				require.Len(t, res.Tasks, 1)
+				require.Equal(t, task.ID, res.Tasks[0].ID)
+				require.Equal(t, []db.TaskTag{{TaskID: task.ID, TagID: tag.ID}}, res.TaskTags)
+				require.Len(t, res.Sessions, 1)
+				require.Equal(t, session.ID, res.Sessions[0].ID)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ExportUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ExportUserTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "user_not_found")
+			},
+		},
+		{
+			name: "InternalServerError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ExportUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ExportUserTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/export"
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.True(t, revoked)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			body: map[string]any{
+				"password": "incorrectPassword",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "MissingPassword",
+			body: map[string]any{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "NotFound",
+			body: map[string]any{
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// another access token of the user issued before the deletion
+			_, otherPayload, err := server.tokenMaker.CreateToken(user.Username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			url := "/users"
+			request, err := http.NewRequest(http.MethodDelete, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+
+			revoked, err := server.denylist.IsRevoked(context.Background(), otherPayload)
+			require.NoError(t, err)
+
+			tc.checkResponse(t, recorder, revoked)
+		})
+	}
+}
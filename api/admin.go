@@ -0,0 +1,263 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern so a search matches them literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// adminUserResponse is a user as seen by an administrator
+type adminUserResponse struct {
+	userResponse
+	LockedAt *time.Time `json:"locked_at"`
+}
+
+func newAdminUserResponse(user db.User) adminUserResponse {
+	return adminUserResponse{
+		userResponse: newUserResponse(user),
+		LockedAt:     user.LockedAt,
+	}
+}
+
+type adminListUsersRequest struct {
+	Offset int32  `form:"offset" requirements:"min=0"`
+	Limit  int32  `form:"limit" requirements:"min=1"`
+	Search string `form:"search"`
+}
+
+// adminListUsers lists every user, the search matches part of the username or the email
+func (server *Server) adminListUsers(w http.ResponseWriter, r *http.Request) {
+	var req adminListUsersRequest
+	err := util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	users, err := server.store.ListUsers(r.Context(), db.ListUsersParams{
+		Search: likeEscaper.Replace(req.Search),
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	})
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	rsp := make([]adminUserResponse, len(users))
+	for i, user := range users {
+		rsp[i] = newAdminUserResponse(user)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(rsp))
+}
+
+type adminUserRequest struct {
+	Username string `uri:"username" requirements:"required"`
+}
+
+// adminLockUser stops a user from logging in and revokes all its sessions and tokens
+func (server *Server) adminLockUser(w http.ResponseWriter, r *http.Request) {
+	var req adminUserRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	if !server.allowAdminAction(w, r, req.Username) {
+		return
+	}
+
+	user, err := server.store.LockUser(r.Context(), req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.revokeUserSessions(r.Context(), user.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), user.Username, time.Now())
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(newAdminUserResponse(user)))
+}
+
+// adminUnlockUser lets a locked user log in again, the tokens issued before the
+// lock keep being denied because the lock moved the tokens_valid_after cutoff of
+// the user
+func (server *Server) adminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	var req adminUserRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	user, err := server.store.UnlockUser(r.Context(), req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(newAdminUserResponse(user)))
+}
+
+// adminDeleteUser removes a user and all its data
+func (server *Server) adminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	var req adminUserRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	if !server.allowAdminAction(w, r, req.Username) {
+		return
+	}
+
+	_, err = server.store.GetUser(r.Context(), req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.store.DeleteUserTx(r.Context(), req.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), req.Username, time.Now())
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowAdminAction writes an error and returns false when an administrator
+// tries to lock or delete its own account
+func (server *Server) allowAdminAction(w http.ResponseWriter, r *http.Request, username string) bool {
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return false
+	}
+
+	if payload.Username == username {
+		writeError(w, newAPIError(http.StatusForbidden, codeForbidden, "administrators can't lock or delete their own account"))
+		return false
+	}
+
+	return true
+}
+
+type adminListTasksRequest struct {
+	Offset int32  `form:"offset" requirements:"min=0"`
+	Limit  int32  `form:"limit" requirements:"min=1"`
+	Owner  string `form:"owner"`
+}
+
+// adminListTasks lists the tasks of every user, or of a single owner
+func (server *Server) adminListTasks(w http.ResponseWriter, r *http.Request) {
+	var req adminListTasksRequest
+	err := util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	tasks, err := server.store.ListAllTasks(r.Context(), db.ListAllTasksParams{
+		Owner:  req.Owner,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	})
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(tasks))
+}
+
+type adminTaskRequest struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+// adminGetTask gets any task regardless of its owner
+func (server *Server) adminGetTask(w http.ResponseWriter, r *http.Request) {
+	var req adminTaskRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	task, valid := server.validTask(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	rsp, err := server.newTaskResponse(r.Context(), task)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(rsp))
+}
+
+// adminDeleteTask deletes any task regardless of its owner
+func (server *Server) adminDeleteTask(w http.ResponseWriter, r *http.Request) {
+	var req adminTaskRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	_, valid := server.validTask(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	err = server.store.DeleteTask(r.Context(), req.ID)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
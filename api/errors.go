@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+// Error codes returned in the error body. They are part of the API contract,
+// clients switch on them so they must never change
+const (
+	codeValidationFailed      = "validation_failed"
+	codeInternalError         = "internal_error"
+	codeTimeout               = "timeout"
+	codeAccessDenied          = "access_denied"
+	codeForbidden             = "forbidden"
+	codeAlreadyExists         = "already_exists"
+	codeInvalidCredentials    = "invalid_credentials"
+	codeTooManyAttempts       = "too_many_attempts"
+	codeAuthorizationMissing  = "authorization_missing"
+	codeAuthorizationInvalid  = "authorization_invalid"
+	codeUnsupportedAuthorType = "unsupported_authorization_type"
+	codeTokenInvalid          = "token_invalid"
+	codeTokenExpired          = "token_expired"
+	codeTokenRevoked          = "token_revoked"
+	codeInsufficientScope     = "insufficient_scope"
+	codeInvalidReference      = "invalid_reference"
+	codeTaskCycle             = "task_cycle"
+	codeTaskNotFound          = "task_not_found"
+	codeTagNotFound           = "tag_not_found"
+)
+
+// apiError is the error returned by every endpoint
+type apiError struct {
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// validationError describes a request that couldn't be bound or validated,
+// listing the invalid fields when the requirements failed
+func validationError(err error) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, codeValidationFailed, err.Error())
+
+	var reqErr *util.RequirementsError
+	if errors.As(err, &reqErr) {
+		apiErr.Message = "some fields are invalid"
+		apiErr.Details = reqErr.Fields
+	}
+
+	return apiErr
+}
+
+// fieldError describes a single invalid field of the request
+func fieldError(field string, err error) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, codeValidationFailed, "some fields are invalid")
+	apiErr.Details = map[string]string{field: err.Error()}
+	return apiErr
+}
+
+// notFoundError describes a missing resource, the code is "<resource>_not_found"
+func notFoundError(resource string) *apiError {
+	return newAPIError(http.StatusNotFound, resource+"_not_found", fmt.Sprintf("%s not found", resource))
+}
+
+// invalidReferenceError describes a request that points to a row that doesn't exist
+func invalidReferenceError() *apiError {
+	return newAPIError(http.StatusBadRequest, codeInvalidReference, "the request references a resource that doesn't exist")
+}
+
+// accessDeniedError describes a resource of another user
+func accessDeniedError(resource string) *apiError {
+	return newAPIError(http.StatusUnauthorized, codeAccessDenied, fmt.Sprintf("%s doesn't belong to the authenticated user", resource))
+}
+
+// tokenError describes an access token that couldn't be verified
+func tokenError(err error) *apiError {
+	if errors.Is(err, token.ErrExpiredToken) {
+		return newAPIError(http.StatusUnauthorized, codeTokenExpired, err.Error())
+	}
+	return newAPIError(http.StatusUnauthorized, codeTokenInvalid, token.ErrInvalidToken.Error())
+}
+
+// internalError logs an unexpected error and hides it from the client,
+// requests that ran out of time get a gateway timeout instead
+func internalError(r *http.Request, err error) *apiError {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		return newAPIError(http.StatusGatewayTimeout, codeTimeout, "the request took too long")
+	}
+
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	return newAPIError(http.StatusInternalServerError, codeInternalError, "internal server error")
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	w.Write(errorResponse(err))
+}
+
+// writeTooManyRequests writes a too many attempts error that tells the client
+// how long to wait before it tries again
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, newAPIError(http.StatusTooManyRequests, codeTooManyAttempts, message))
+}
+
+func errorResponse(err *apiError) []byte {
+	type res struct {
+		Error *apiError `json:"error"`
+	}
+
+	return jsonResponse(res{Error: err})
+}
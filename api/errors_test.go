@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationError(t *testing.T) {
+	type testRequest struct {
+		Title    string `json:"title" requirements:"required"`
+		Priority string `json:"priority" requirements:"oneof=low high"`
+	}
+
+	err := util.VerifyRequirements(testRequest{Priority: "medium"})
+	require.Error(t, err)
+
+	apiErr := validationError(err)
+	require.Equal(t, http.StatusBadRequest, apiErr.Status)
+	require.Equal(t, codeValidationFailed, apiErr.Code)
+	require.Len(t, apiErr.Details, 2)
+	require.Contains(t, apiErr.Details, "title")
+	require.Contains(t, apiErr.Details, "priority")
+
+	apiErr = validationError(errors.New("unexpected end of JSON input"))
+	require.Equal(t, codeValidationFailed, apiErr.Code)
+	require.Empty(t, apiErr.Details)
+}
+
+func TestTokenError(t *testing.T) {
+	apiErr := tokenError(token.ErrExpiredToken)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	require.Equal(t, codeTokenExpired, apiErr.Code)
+
+	apiErr = tokenError(token.ErrInvalidToken)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	require.Equal(t, codeTokenInvalid, apiErr.Code)
+}
+
+func TestInternalError(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+
+	// the raw error must not reach the client
+	err := fmt.Errorf("pq: relation \"tasks\" does not exist")
+	apiErr := internalError(request, err)
+	require.Equal(t, http.StatusInternalServerError, apiErr.Status)
+	require.Equal(t, codeInternalError, apiErr.Code)
+	require.NotContains(t, apiErr.Message, "pq")
+
+	apiErr = internalError(request, sql.ErrConnDone)
+	require.Equal(t, codeInternalError, apiErr.Code)
+
+	apiErr = internalError(request, context.DeadlineExceeded)
+	require.Equal(t, http.StatusGatewayTimeout, apiErr.Status)
+	require.Equal(t, codeTimeout, apiErr.Code)
+}
+
+func TestWriteError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeError(recorder, notFoundError("task"))
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var res map[string]map[string]any
+	err := json.Unmarshal(recorder.Body.Bytes(), &res)
+	require.NoError(t, err)
+	require.Equal(t, "task_not_found", res["error"]["code"])
+	require.Equal(t, "task not found", res["error"]["message"])
+	require.NotContains(t, res["error"], "details")
+}
+
+func requireErrorCode(t *testing.T, body *bytes.Buffer, code string) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var res struct {
+		Error apiError `json:"error"`
+	}
+	err = json.Unmarshal(data, &res)
+	require.NoError(t, err)
+	require.Equal(t, code, res.Error.Code)
+	require.NotEmpty(t, res.Error.Message)
+}
@@ -1,21 +1,43 @@
 package api
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/denylist"
+	"github.com/DMV-Nicolas/DevoraTasks/mail"
 	"github.com/DMV-Nicolas/DevoraTasks/util"
 	"github.com/stretchr/testify/require"
 )
 
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomPassword(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    util.RandomPassword(32),
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
 	}
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
 
+	// keep the revoked tokens in memory so the store mocks only expect the handler queries
+	server.denylist = denylist.NewMemoryDenylist()
+	server.mailer = &testMailer{}
+
 	return server
 }
+
+// testMailer keeps the sent emails so the tests can read them
+type testMailer struct {
+	emails []mail.Email
+	err    error
+}
+
+func (mailer *testMailer) SendEmail(ctx context.Context, email mail.Email) error {
+	if mailer.err != nil {
+		return mailer.err
+	}
+	mailer.emails = append(mailer.emails, email)
+	return nil
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/totp"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets when enrolling
+	recoveryCodeCount = 10
+	// recoveryCodeSize is the number of random bytes of a recovery code, enough
+	// that the stored hashes can't be brute forced
+	recoveryCodeSize = 10
+	// mfaTokenSize is the number of random bytes of an mfa challenge token
+	mfaTokenSize = 32
+	// mfaMaxAttempts is how many codes can be tried against an mfa challenge
+	mfaMaxAttempts = 5
+
+	codeMFAEnabled      = "mfa_already_enabled"
+	codeMFACodeInvalid  = "mfa_code_invalid"
+	codeMFATokenInvalid = "mfa_token_invalid"
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type enrollTotpResponse struct {
+	Secret        string   `json:"secret"`
+	OtpauthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// enrollTotp creates a new totp secret for the authenticated user together with
+// its recovery codes. The secret isn't required to log in until it's confirmed
+func (server *Server) enrollTotp(w http.ResponseWriter, r *http.Request) {
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			writeError(w, internalError(r, err))
+			return
+		}
+		hashes[i] = util.HashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	arg := db.EnrollTotpTxParams{
+		CreateUserTotpParams: db.CreateUserTotpParams{
+			Username: payload.Username,
+			Secret:   secret,
+		},
+		RecoveryCodeHashes: hashes,
+	}
+
+	_, err = server.store.EnrollTotpTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrTotpEnabled {
+			writeError(w, newAPIError(http.StatusForbidden, codeMFAEnabled, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := enrollTotpResponse{
+		Secret:        secret,
+		OtpauthURI:    totp.URI(server.config.MFAIssuer, payload.Username, secret),
+		RecoveryCodes: codes,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse(res))
+}
+
+type confirmTotpRequest struct {
+	Code string `json:"code" requirements:"required"`
+}
+
+// confirmTotp checks the first code of the authenticator app of the
+// authenticated user, from then on the login asks for a code too
+func (server *Server) confirmTotp(w http.ResponseWriter, r *http.Request) {
+	var req confirmTotpRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	userTotp, err := server.store.GetUserTotp(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("totp"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	if userTotp.ConfirmedAt != nil {
+		writeError(w, newAPIError(http.StatusForbidden, codeMFAEnabled, db.ErrTotpEnabled.Error()))
+		return
+	}
+
+	step, ok := totp.Validate(userTotp.Secret, req.Code, time.Now())
+	if !ok {
+		writeError(w, newAPIError(http.StatusBadRequest, codeMFACodeInvalid, "invalid authentication code"))
+		return
+	}
+
+	arg := db.ConfirmUserTotpParams{
+		Username:     payload.Username,
+		LastUsedStep: step,
+	}
+
+	_, err = server.store.ConfirmUserTotp(r.Context(), arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, newAPIError(http.StatusForbidden, codeMFAEnabled, db.ErrTotpEnabled.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type mfaChallengeResponse struct {
+	MFARequired       bool      `json:"mfa_required"`
+	MFAToken          string    `json:"mfa_token"`
+	MFATokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+}
+
+// createMFAChallenge writes the token that must be exchanged together with a
+// code of the authenticator app to finish the login of the user
+func (server *Server) createMFAChallenge(w http.ResponseWriter, r *http.Request, user db.User) {
+	token, err := util.SecureToken(mfaTokenSize)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	arg := db.CreateMfaChallengeParams{
+		Username:  user.Username,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: time.Now().Add(server.config.MFAChallengeDuration),
+	}
+
+	challenge, err := server.store.CreateMfaChallenge(r.Context(), arg)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := mfaChallengeResponse{
+		MFARequired:       true,
+		MFAToken:          token,
+		MFATokenExpiresAt: challenge.ExpiresAt,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+type loginUserMFARequest struct {
+	MFAToken string `json:"mfa_token" requirements:"required"`
+	Code     string `json:"code" requirements:"required"`
+}
+
+// loginUserMFA exchanges the token of an mfa challenge and a code of the
+// authenticator app, or one of the recovery codes, for a new session
+func (server *Server) loginUserMFA(w http.ResponseWriter, r *http.Request) {
+	var req loginUserMFARequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	challenge, err := server.store.GetMfaChallenge(r.Context(), util.HashToken(req.MFAToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, mfaTokenError())
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	attempt, ok := server.reserveLogin(w, challenge.Username, clientIP(r))
+	if !ok {
+		return
+	}
+	defer attempt.release()
+
+	// the attempt is counted before the code is checked, so concurrent guesses
+	// can't go over the limit of the challenge
+	_, err = server.store.AttemptMfaChallenge(r.Context(), db.AttemptMfaChallengeParams{
+		ID:          challenge.ID,
+		MaxAttempts: mfaMaxAttempts,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, mfaTokenError())
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	userTotp, err := server.store.GetUserTotp(r.Context(), challenge.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, mfaTokenError())
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	// the challenge and the code are used in a single transaction, so
+	// concurrent requests can't spend a code on an already used challenge
+	arg, ok := mfaChallengeParams(challenge, userTotp, req.Code)
+	if ok {
+		_, err = server.store.UseMfaChallengeTx(r.Context(), arg)
+	} else {
+		err = db.ErrInvalidMfaCode
+	}
+	switch {
+	case err == db.ErrInvalidMfaCode:
+		attempt.fail()
+		writeError(w, newAPIError(http.StatusUnauthorized, codeMFACodeInvalid, "invalid authentication code"))
+		return
+	case err == db.ErrInvalidMfaToken:
+		writeError(w, mfaTokenError())
+		return
+	case err != nil:
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	user, err := server.store.GetUser(r.Context(), challenge.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	server.loginUsers.Reset(user.Username)
+	server.startSession(w, r, user)
+}
+
+// mfaChallengeParams returns the params that use the challenge with a totp
+// code or a recovery code of the user, it returns false when the code can't
+// be valid. The code is only spent once the challenge is used
+func mfaChallengeParams(challenge db.MfaChallenge, userTotp db.UserTotp, code string) (db.UseMfaChallengeTxParams, bool) {
+	if userTotp.ConfirmedAt == nil {
+		return db.UseMfaChallengeTxParams{}, false
+	}
+
+	arg := db.UseMfaChallengeTxParams{
+		ChallengeID: challenge.ID,
+		Username:    userTotp.Username,
+	}
+
+	step, ok := totp.Validate(userTotp.Secret, code, time.Now())
+	if ok {
+		arg.TotpStep = step
+	} else {
+		arg.RecoveryCodeHash = util.HashToken(normalizeRecoveryCode(code))
+	}
+
+	return arg, true
+}
+
+func mfaTokenError() *apiError {
+	return newAPIError(http.StatusUnauthorized, codeMFATokenInvalid, "the mfa token is invalid, expired or was already used")
+}
+
+// newRecoveryCode returns a random code formatted as groups of four lowercase
+// letters and digits, like "abcd-ef23-ghij-kl45"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	groups := make([]string, 0, len(code)/4)
+	for i := 0; i < len(code); i += 4 {
+		groups = append(groups, code[i:min(i+4, len(code))])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode removes the separators and the case of a recovery code
+// so it's accepted however the user typed it
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
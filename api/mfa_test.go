@@ -0,0 +1,667 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/totp"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func randomUserTotp(t *testing.T, username string, confirmed bool) db.UserTotp {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	userTotp := db.UserTotp{
+		Username:  username,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	if confirmed {
+		confirmedAt := time.Now()
+		userTotp.ConfirmedAt = &confirmedAt
+	}
+
+	return userTotp
+}
+
+func currentTotpCode(t *testing.T, secret string) string {
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+func TestEnrollTotpAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore, hashes *[]string)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, hashes []string)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, hashes *[]string) {
+				store.EXPECT().
+					EnrollTotpTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.EnrollTotpTxParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						*hashes = arg.RecoveryCodeHashes
+						return db.UserTotp{Username: arg.Username, Secret: arg.Secret}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, hashes []string) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res enrollTotpResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.NotEmpty(t, res.Secret)
+				require.True(t, strings.HasPrefix(res.OtpauthURI, "otpauth://totp/"))
+				require.Contains(t, res.OtpauthURI, "secret="+res.Secret)
+
+				// only the hashes of the recovery codes are stored
+				require.Len(t, res.RecoveryCodes, recoveryCodeCount)
+				require.Len(t, hashes, recoveryCodeCount)
+				for i, code := range res.RecoveryCodes {
+					require.Regexp(t, `^([a-z2-7]{4}-){3}[a-z2-7]{4}$`, code)
+					require.Equal(t, util.HashToken(normalizeRecoveryCode(code)), hashes[i])
+				}
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, hashes *[]string) {
+				store.EXPECT().
+					EnrollTotpTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, db.ErrTotpEnabled)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, hashes []string) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFAEnabled)
+			},
+		},
+		{
+			name: "InternalServerError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, hashes *[]string) {
+				store.EXPECT().
+					EnrollTotpTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, hashes []string) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore, hashes *[]string) {
+				store.EXPECT().
+					EnrollTotpTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, hashes []string) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var hashes []string
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store, &hashes)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/mfa/totp"
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, hashes)
+		})
+	}
+}
+
+func TestConfirmTotpAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	userTotp := randomUserTotp(t, user.Username, false)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"code": currentTotpCode(t, userTotp.Secret),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ConfirmUserTotpParams{
+					Username:     user.Username,
+					LastUsedStep: totp.Step(time.Now()),
+				}
+
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					ConfirmUserTotp(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(userTotp, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: map[string]any{
+				"code": "000000",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// the secret used in the test could produce 000000, use another one
+				other := userTotp
+				other.Secret = "JBSWY3DPEHPK3PXP"
+				if currentTotpCode(t, other.Secret) == "000000" {
+					other.Secret = "KRSXG5CTMVRXEZLU"
+				}
+
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(other, nil)
+				store.EXPECT().
+					ConfirmUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFACodeInvalid)
+			},
+		},
+		{
+			name: "AlreadyConfirmed",
+			body: map[string]any{
+				"code": currentTotpCode(t, userTotp.Secret),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				confirmedAt := time.Now()
+				confirmed := userTotp
+				confirmed.ConfirmedAt = &confirmedAt
+
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(confirmed, nil)
+				store.EXPECT().
+					ConfirmUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFAEnabled)
+			},
+		},
+		{
+			name: "ConfirmedConcurrently",
+			body: map[string]any{
+				"code": currentTotpCode(t, userTotp.Secret),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					ConfirmUserTotp(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFAEnabled)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			body: map[string]any{
+				"code": "123456",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "totp_not_found")
+			},
+		},
+		{
+			name: "MissingCode",
+			body: map[string]any{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: map[string]any{
+				"code": "123456",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/mfa/totp/confirm"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserMFAAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	userTotp := randomUserTotp(t, user.Username, true)
+	mfaToken := util.RandomPassword(32)
+	recoveryCode := "abcd-ef23-ghij-kl45"
+
+	challenge := db.MfaChallenge{
+		ID:        int64(util.RandomInt(1, 1000)),
+		Username:  user.Username,
+		TokenHash: util.HashToken(mfaToken),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	attemptArg := db.AttemptMfaChallengeParams{
+		ID:          challenge.ID,
+		MaxAttempts: mfaMaxAttempts,
+	}
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UseMfaChallengeTxParams{
+					ChallengeID: challenge.ID,
+					Username:    user.Username,
+					TotpStep:    totp.Step(time.Now()),
+				}
+
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					UseMfaChallengeTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.NotEmpty(t, res.AccessToken)
+				require.NotEmpty(t, res.RefreshToken)
+				require.Equal(t, user.Username, res.User.Username)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      strings.ToUpper(recoveryCode),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UseMfaChallengeTxParams{
+					ChallengeID:      challenge.ID,
+					Username:         user.Username,
+					RecoveryCodeHash: util.HashToken(normalizeRecoveryCode(recoveryCode)),
+				}
+
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					UseMfaChallengeTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ReusedCode",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					UseMfaChallengeTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, db.ErrInvalidMfaCode)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFACodeInvalid)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      "wrong-code",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					UseMfaChallengeTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, db.ErrInvalidMfaCode)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFACodeInvalid)
+			},
+		},
+		{
+			name: "InvalidToken",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFATokenInvalid)
+			},
+		},
+		{
+			name: "TooManyAttempts",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				failed := challenge
+				failed.Attempts = mfaMaxAttempts
+
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(failed, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFATokenInvalid)
+			},
+		},
+		{
+			name: "UsedConcurrently",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					AttemptMfaChallenge(gomock.Any(), gomock.Eq(attemptArg)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTotp, nil)
+				store.EXPECT().
+					UseMfaChallengeTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, db.ErrInvalidMfaToken)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeMFATokenInvalid)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+				"code":      currentTotpCode(t, userTotp.Secret),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "MissingCode",
+			body: map[string]any{
+				"mfa_token": mfaToken,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/login/mfa"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
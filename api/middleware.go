@@ -1,61 +1,189 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"runtime/debug"
+	"slices"
 	"strings"
 
 	"github.com/DMV-Nicolas/DevoraTasks/token"
-	ctx "github.com/gorilla/context"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
-	authorizationPayloadKey = "authorization_payload"
 )
 
-func authMiddleware(nextHandler http.HandlerFunc, tokenMaker token.Maker) http.HandlerFunc {
+// contextKey is the type of the keys stored by the api in request contexts,
+// so they can't collide with keys set by other packages
+type contextKey string
+
+const authorizationPayloadKey contextKey = "authorization_payload"
+
+// PayloadFromContext returns the token payload stored by the auth middleware
+func PayloadFromContext(ctx context.Context) (*token.Payload, bool) {
+	payload, ok := ctx.Value(authorizationPayloadKey).(*token.Payload)
+	return payload, ok && payload != nil
+}
+
+// requirePayload gets the token payload of the request, writing an unauthorized
+// response when the request didn't go through the auth middleware
+func requirePayload(w http.ResponseWriter, r *http.Request) (*token.Payload, bool) {
+	payload, ok := PayloadFromContext(r.Context())
+	if !ok {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeAuthorizationMissing, "authorization payload is not provided"))
+		return nil, false
+	}
+
+	return payload, true
+}
+
+// authMiddleware verifies the access token of the request and rejects the
+// tokens that were revoked or issued before the last password change of the user.
+// Personal access tokens are accepted too, scoped tokens must grant all the
+// scopes of the route
+func (server *Server) authMiddleware(nextHandler http.HandlerFunc, scopes ...string) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authorizationHeader := r.Header.Get(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
-			err := fmt.Errorf("authorization header is not provided")
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeError(w, newAPIError(http.StatusUnauthorized, codeAuthorizationMissing, "authorization header is not provided"))
 			return
 		}
 
 		fields := strings.Fields(authorizationHeader)
 		if len(fields) < 2 {
-			err := fmt.Errorf("invalid authorization header format")
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeError(w, newAPIError(http.StatusUnauthorized, codeAuthorizationInvalid, "invalid authorization header format"))
+			return
 		}
 
 		authorizationType := strings.ToLower(fields[0])
 		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			message := fmt.Sprintf("unsupported authorization type %s", authorizationType)
+			writeError(w, newAPIError(http.StatusUnauthorized, codeUnsupportedAuthorType, message))
 			return
 		}
 
 		accessToken := fields[1]
-		payload, err := tokenMaker.VerifyToken(accessToken)
+		var payload *token.Payload
+		if strings.HasPrefix(accessToken, personalAccessTokenPrefix) {
+			var valid bool
+			payload, valid = server.verifyPersonalAccessToken(w, r, accessToken, scopes)
+			if !valid {
+				return
+			}
+		} else {
+			var err error
+			payload, err = server.tokenMaker.VerifyToken(accessToken, token.TokenTypeAccessToken)
+			if err != nil {
+				writeError(w, tokenError(err))
+				return
+			}
+			if !requireScopes(w, payload, scopes) {
+				return
+			}
+		}
+
+		revoked, err := server.denylist.IsRevoked(r.Context(), payload)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeError(w, internalError(r, err))
+			return
+		}
+		if revoked {
+			writeError(w, newAPIError(http.StatusUnauthorized, codeTokenRevoked, "token has been revoked"))
+			return
+		}
+
+		payloadCtx := context.WithValue(r.Context(), authorizationPayloadKey, payload)
+		nextHandler.ServeHTTP(w, r.WithContext(payloadCtx))
+	})
+}
+
+// requireScopes checks that a token limited to some scopes grants all the
+// scopes of the route. Tokens without scopes, like the login ones, can use
+// every route and the scoped ones can't use the routes without scopes
+func requireScopes(w http.ResponseWriter, payload *token.Payload, scopes []string) bool {
+	if payload.Scopes == nil {
+		return true
+	}
+
+	if len(scopes) == 0 {
+		writeError(w, newAPIError(http.StatusForbidden, codeInsufficientScope, "this endpoint can't be used with a scoped token"))
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(payload.Scopes, scope) {
+			writeError(w, newAPIError(http.StatusForbidden, codeInsufficientScope, fmt.Sprintf("the token is missing the %s scope", scope)))
+			return false
+		}
+	}
+
+	return true
+}
+
+// roleMiddleware lets the request through only when the authenticated user has
+// one of the roles, it must run after the auth middleware. Personal access
+// tokens don't carry a role so they are always rejected
+func roleMiddleware(nextHandler http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, valid := requirePayload(w, r)
+		if !valid {
+			return
+		}
+
+		if !slices.Contains(roles, payload.Role) {
+			writeError(w, newAPIError(http.StatusForbidden, codeForbidden, "the authenticated user doesn't have the required role"))
 			return
 		}
 
-		ctx.Set(r, authorizationPayloadKey, payload)
 		nextHandler.ServeHTTP(w, r)
 	})
 }
 
+// recoveryMiddleware turns a panic in a handler into an internal server error
+// instead of dropping the connection
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+				writeError(w, newAPIError(http.StatusInternalServerError, codeInternalError, "internal server error"))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:52330")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 			next.ServeHTTP(w, req)
 		})
 }
+
+// timeoutMiddleware sets the configured deadline on the request context so every
+// store call made while handling the request is cancelled once it expires
+func (server *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.config.DBTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(r.Context(), server.config.DBTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(timeoutCtx))
+	})
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/denylist"
 	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/o1egl/paseto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,8 +26,21 @@ func addAuthorization(
 	username string,
 	duration time.Duration,
 ) {
-	token, err := tokenMaker.CreateToken(username, duration)
+	addRoleAuthorization(t, request, tokenMaker, authorizationType, username, util.UserRole, duration)
+}
+
+func addRoleAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	username string,
+	role string,
+	duration time.Duration,
+) {
+	token, payload, err := tokenMaker.CreateToken(username, role, duration, token.TokenTypeAccessToken)
 	require.NoError(t, err)
+	require.NotEmpty(t, payload)
 
 	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
@@ -49,6 +69,7 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAuthorizationMissing)
 			},
 		},
 		{
@@ -58,6 +79,7 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeUnsupportedAuthorType)
 			},
 		},
 		{
@@ -67,6 +89,7 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAuthorizationInvalid)
 			},
 		},
 		{
@@ -76,6 +99,29 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTokenExpired)
+			},
+		},
+		{
+			name: "RefreshToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				refreshToken, _, err := tokenMaker.CreateToken("user", util.UserRole, time.Hour, token.TokenTypeRefreshToken)
+				require.NoError(t, err)
+				request.Header.Set(authorizationHeaderKey, "bearer "+refreshToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTokenInvalid)
+			},
+		},
+		{
+			name: "InvalidToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, "bearer invalid-token")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTokenInvalid)
 			},
 		},
 	}
@@ -84,10 +130,10 @@ func TestAuthMiddleware(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			server := newTestServer(t, nil)
 			authPath := "/auth"
-			server.router.HandleFunc(authPath, authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			server.router.HandleFunc(authPath, server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("OK"))
-			}, server.tokenMaker))
+			}))
 
 			recorder := httptest.NewRecorder()
 			request, err := http.NewRequest(http.MethodGet, authPath, nil)
@@ -99,3 +145,254 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeoutMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.config.DBTimeout = 10 * time.Millisecond
+
+	timeoutPath := "/timeout"
+	server.router.HandleFunc(timeoutPath, func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		require.True(t, ok)
+
+		<-r.Context().Done()
+		err := r.Context().Err()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		writeError(w, internalError(r, err))
+	})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, timeoutPath, nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTimeout)
+}
+
+func TestPayloadFromContext(t *testing.T) {
+	payload, err := token.NewPayload(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	gotPayload, ok := PayloadFromContext(context.Background())
+	require.False(t, ok)
+	require.Nil(t, gotPayload)
+
+	ctx := context.WithValue(context.Background(), authorizationPayloadKey, payload)
+	gotPayload, ok = PayloadFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, payload, gotPayload)
+
+	// keys of other packages with the same name must not match
+	ctx = context.WithValue(context.Background(), string(authorizationPayloadKey), payload)
+	gotPayload, ok = PayloadFromContext(ctx)
+	require.False(t, ok)
+	require.Nil(t, gotPayload)
+}
+
+func TestMissingPayload(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// a handler registered without the auth middleware must not panic
+	noAuthPath := "/no-auth"
+	server.router.HandleFunc(noAuthPath, server.getUser)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, noAuthPath, nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeAuthorizationMissing)
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	panicPath := "/panic"
+	server.router.HandleFunc(panicPath, func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, panicPath, nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeInternalError)
+}
+
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
+	server := newTestServer(t, nil)
+	authPath := "/auth"
+	server.router.HandleFunc(authPath, server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	accessToken, payload, err := server.tokenMaker.CreateToken("user", util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	sendRequest := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, authPath, nil)
+		require.NoError(t, err)
+
+		request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := sendRequest()
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	err = server.denylist.Revoke(context.Background(), payload.ID, payload.ExpiresAt)
+	require.NoError(t, err)
+
+	recorder = sendRequest()
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTokenRevoked)
+}
+
+func TestRoleMiddleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		role         string
+		expectedCode int
+	}{
+		{name: "Admin", role: util.AdminRole, expectedCode: http.StatusOK},
+		{name: "User", role: util.UserRole, expectedCode: http.StatusForbidden},
+		{name: "NoRole", role: "", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			adminPath := "/admin"
+			server.router.HandleFunc(adminPath, server.authMiddleware(roleMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}, util.AdminRole)))
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, adminPath, nil)
+			require.NoError(t, err)
+
+			addRoleAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusForbidden {
+				requireErrorCode(t, recorder.Body, codeForbidden)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareScopedToken(t *testing.T) {
+	testCases := []struct {
+		name         string
+		scopes       []string
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{
+			name:         "ReadOnlyCanRead",
+			scopes:       []string{scopeTasksRead},
+			method:       http.MethodGet,
+			path:         "/read",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "ReadOnlyCantDeleteTasks",
+			scopes:       []string{scopeTasksRead},
+			method:       http.MethodDelete,
+			path:         "/tasks",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "RouteWithoutScopes",
+			scopes:       []string{scopeTasksRead, scopeTasksWrite},
+			method:       http.MethodPatch,
+			path:         "/users",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "NoScopes",
+			method:       http.MethodGet,
+			path:         "/read",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			server.router.HandleFunc("/read", server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}, scopeTasksRead))
+
+			payload, err := token.NewPayload(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			payload.Scopes = tc.scopes
+
+			// the makers don't create scoped tokens, so the token is encrypted
+			// with the key of the test server
+			accessToken, err := paseto.NewV2().Encrypt([]byte(server.config.TokenSymmetricKey), payload, nil)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(tc.method, tc.path, nil)
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, "Bearer "+accessToken)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusForbidden {
+				requireErrorCode(t, recorder.Body, codeInsufficientScope)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareJWTIssuedAfterPasswordChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+
+	config := util.Config{
+		TokenType:           "jwt",
+		TokenSymmetricKey:   util.RandomPassword(32),
+		AccessTokenDuration: time.Minute,
+	}
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	server.denylist = denylist.NewStoreDenylist(store)
+
+	authPath := "/auth"
+	server.router.HandleFunc(authPath, server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// the password changes a moment before the login, in the same second
+	passwordChangedAt := time.Now()
+	accessToken, _, err := server.tokenMaker.CreateToken("user", util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	// the store compares the issue time like the IsTokenRevoked query does
+	store.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.IsTokenRevokedParams) (bool, error) {
+			return passwordChangedAt.After(arg.IssuedAt), nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, authPath, nil)
+	require.NoError(t, err)
+
+	request.Header.Set(authorizationHeaderKey, "Bearer "+accessToken)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/mail"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+const (
+	// resetCodeSize is the number of random bytes of a password reset code
+	resetCodeSize = 32
+
+	codeResetCodeInvalid = "reset_code_invalid"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" requirements:"required;email"`
+}
+
+// forgotPassword emails a one-time reset code to the owner of the email. The
+// code is created and sent in the background and the response is always 202, so
+// neither the status nor the response time tell which emails are registered
+func (server *Server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	if !server.reservePasswordReset(w, req.Email, clientIP(r)) {
+		return
+	}
+
+	// the job outlives the request, so it gets its own deadline for the
+	// queries and the email instead of the one of the request
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), server.config.DBTimeout+server.mailTimeout())
+	server.runInBackground(func() {
+		defer cancel()
+		err := server.sendPasswordReset(ctx, req.Email)
+		if err != nil {
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// reservePasswordReset counts a password reset request of the email and the
+// client ip, it writes an error and returns false when either of them made too
+// many requests. Unknown emails are counted too, so the lockout doesn't tell
+// which emails are registered
+func (server *Server) reservePasswordReset(w http.ResponseWriter, email string, ip string) bool {
+	email = strings.ToLower(email)
+
+	wait, ok := server.resetIPs.Reserve(ip)
+	if ok {
+		wait, ok = server.resetEmails.Reserve(email)
+		if !ok {
+			server.resetIPs.Release(ip)
+		}
+	}
+	if !ok {
+		writeTooManyRequests(w, wait, "too many password reset requests, try again later")
+		return false
+	}
+
+	// every request is counted as a failure, so the lockout spaces them out
+	server.resetEmails.Fail(email)
+	server.resetIPs.Fail(ip)
+	return true
+}
+
+// sendPasswordReset creates a reset code for the owner of the email and emails
+// it. Unknown emails are ignored
+func (server *Server) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := server.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	code, err := util.SecureToken(resetCodeSize)
+	if err != nil {
+		return err
+	}
+
+	arg := db.CreatePasswordResetParams{
+		Username:  user.Username,
+		CodeHash:  util.HashToken(code),
+		ExpiresAt: time.Now().Add(server.config.PasswordResetDuration),
+	}
+
+	_, err = server.store.CreatePasswordReset(ctx, arg)
+	if err != nil {
+		return err
+	}
+
+	return server.mailer.SendEmail(ctx, mail.Email{
+		To:      []string{user.Email},
+		Subject: "Reset your DevoraTasks password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse this code to reset your password: %s\n\nThe code expires in %s. If you didn't ask for it you can ignore this email.",
+			user.Username, code, server.config.PasswordResetDuration,
+		),
+	})
+}
+
+type resetPasswordRequest struct {
+	Code        string `json:"code" requirements:"required"`
+	NewPassword string `json:"new_password" requirements:"required;min=8"`
+}
+
+// resetPassword replaces the password of the user that owns the reset code, the
+// code can't be used again and every token issued before the reset stops working
+func (server *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	arg := db.ResetPasswordTxParams{
+		CodeHash:          util.HashToken(req.Code),
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	result, err := server.store.ResetPasswordTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrInvalidResetCode {
+			writeError(w, newAPIError(http.StatusBadRequest, codeResetCodeInvalid, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), result.User.Username, result.User.PasswordChangedAt)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	for _, session := range result.Sessions {
+		err = server.denylist.Revoke(r.Context(), session.ID, session.ExpiresAt)
+		if err != nil {
+			writeError(w, internalError(r, err))
+			return
+		}
+	}
+
+	res := newUserResponse(result.User)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
@@ -0,0 +1,542 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/mail"
+	"github.com/DMV-Nicolas/DevoraTasks/throttle"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type eqResetPasswordTxParamsMatcher struct {
+	code     string
+	password string
+}
+
+func (e eqResetPasswordTxParamsMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.ResetPasswordTxParams)
+	if !ok {
+		return false
+	}
+
+	err := util.CheckPassword(e.password, arg.HashedPassword)
+	if err != nil {
+		return false
+	}
+
+	return arg.CodeHash == util.HashToken(e.code) && time.Since(arg.PasswordChangedAt) < time.Minute
+}
+
+func (e eqResetPasswordTxParamsMatcher) String() string {
+	return fmt.Sprintf("matches code %v and password %v", e.code, e.password)
+}
+
+func EqResetPasswordTxParams(code, password string) gomock.Matcher {
+	return eqResetPasswordTxParamsMatcher{code, password}
+}
+
+func TestForgotPasswordAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		mailerErr     error
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"email": user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.WithinDuration(t, time.Now().Add(15*time.Minute), arg.ExpiresAt, time.Second)
+						return db.PasswordReset{
+							ID:        1,
+							Username:  arg.Username,
+							CodeHash:  arg.CodeHash,
+							ExpiresAt: arg.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, mailer.emails, 1)
+				require.Equal(t, []string{user.Email}, mailer.emails[0].To)
+			},
+		},
+		{
+			name: "UnknownEmail",
+			body: map[string]any{
+				"email": user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, mailer.emails)
+			},
+		},
+		{
+			name: "InvalidEmail",
+			body: map[string]any{
+				"email": "invalid-email",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+				require.Empty(t, mailer.emails)
+			},
+		},
+		{
+			name: "MailerError",
+			body: map[string]any{
+				"email": user.Email,
+			},
+			mailerErr: errors.New("smtp server is down"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordReset{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, mailer.emails)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"email": user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, mailer.emails)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			server.config.PasswordResetDuration = 15 * time.Minute
+			mailer := &testMailer{err: tc.mailerErr}
+			server.mailer = mailer
+			recorder := httptest.NewRecorder()
+
+			url := "/users/password/forgot"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			server.background.Wait()
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
+
+func TestForgotPasswordRespondsBeforeSending(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreatePasswordReset(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.PasswordReset{}, nil)
+
+	server := newTestServer(t, store)
+	mailer := &blockingMailer{release: make(chan struct{})}
+	server.mailer = mailer
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(map[string]any{"email": user.Email})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users/password/forgot", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// the mailer doesn't return until it is released, so the handler can only
+	// respond if the email is sent in the background
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	close(mailer.release)
+	server.background.Wait()
+	require.Len(t, mailer.emails, 1)
+	require.Equal(t, []string{user.Email}, mailer.emails[0].To)
+}
+
+func TestForgotPasswordStalledMailer(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreatePasswordReset(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.PasswordReset{}, nil)
+
+	server := newTestServer(t, store)
+	server.config.MailTimeout = 50 * time.Millisecond
+	mailer := &blockingMailer{release: make(chan struct{})}
+	server.mailer = mailer
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(map[string]any{"email": user.Email})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users/password/forgot", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// the mailer is never released, the background job must give up on its own
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	server.background.Wait()
+	require.Empty(t, mailer.emails)
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Any()).
+		Times(3).
+		Return(db.User{}, sql.ErrNoRows)
+
+	server := newTestServer(t, store)
+	server.resetEmails = throttle.New(2, time.Minute, time.Hour)
+	server.resetIPs = throttle.New(3, time.Minute, time.Hour)
+
+	forgotPassword := func(email string) *httptest.ResponseRecorder {
+		data, err := json.Marshal(map[string]any{"email": email})
+		require.NoError(t, err)
+
+		request, err := http.NewRequest(http.MethodPost, "/users/password/forgot", bytes.NewReader(data))
+		require.NoError(t, err)
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		server.background.Wait()
+		return recorder
+	}
+
+	// the email is throttled whatever its case
+	require.Equal(t, http.StatusAccepted, forgotPassword(user.Email).Code)
+	require.Equal(t, http.StatusAccepted, forgotPassword(strings.ToUpper(user.Email)).Code)
+
+	recorder := forgotPassword(user.Email)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTooManyAttempts)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	// the client ip is throttled across emails
+	require.Equal(t, http.StatusAccepted, forgotPassword(util.RandomEmail()).Code)
+
+	recorder = forgotPassword(util.RandomEmail())
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTooManyAttempts)
+}
+
+// blockingMailer waits for release before it sends an email, it gives up when
+// ctx is done. It keeps when it was called and the deadline of its ctx
+type blockingMailer struct {
+	testMailer
+	release     chan struct{}
+	calledAt    time.Time
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (mailer *blockingMailer) SendEmail(ctx context.Context, email mail.Email) error {
+	mailer.calledAt = time.Now()
+	mailer.deadline, mailer.hasDeadline = ctx.Deadline()
+
+	select {
+	case <-mailer.release:
+		return mailer.testMailer.SendEmail(ctx, email)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestForgotPasswordEmailsCode(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var codeHash string
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreatePasswordReset(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+			codeHash = arg.CodeHash
+			return db.PasswordReset{Username: arg.Username, CodeHash: arg.CodeHash}, nil
+		})
+
+	server := newTestServer(t, store)
+	mailer := server.mailer.(*testMailer)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(map[string]any{"email": user.Email})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users/password/forgot", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	server.background.Wait()
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Len(t, mailer.emails, 1)
+
+	// only the hash of the code is stored, the code itself is only in the email
+	body := mailer.emails[0].Body
+	require.NotContains(t, body, codeHash)
+
+	var code string
+	for _, field := range strings.Fields(body) {
+		if util.HashToken(field) == codeHash {
+			code = field
+		}
+	}
+	require.NotEmpty(t, code)
+}
+
+func TestResetPasswordAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	code := util.RandomPassword(32)
+	newPassword := util.RandomPassword(16)
+	session := db.Session{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		IsBlocked: true,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	var passwordChangedAt time.Time
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"code":         code,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), EqResetPasswordTxParams(code, newPassword)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						passwordChangedAt = arg.PasswordChangedAt
+						updatedUser := user
+						updatedUser.HashedPassword = arg.HashedPassword
+						updatedUser.PasswordChangedAt = arg.PasswordChangedAt
+						return db.ResetPasswordTxResult{
+							User:     updatedUser,
+							Sessions: []db.Session{session},
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.True(t, revoked)
+
+				var res userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, res.Username)
+				requirePasswordChangedAt(t, passwordChangedAt, res.PasswordChangedAt)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: map[string]any{
+				"code":         code,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, db.ErrInvalidResetCode)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeResetCodeInvalid)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "TooShortPassword",
+			body: map[string]any{
+				"code":         code,
+				"new_password": "1234",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "MissingCode",
+			body: map[string]any{
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"code":         code,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			_, accessPayload, err := server.tokenMaker.CreateToken(user.Username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			url := "/users/password/reset"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+
+			// tokens issued before the reset stop working
+			revoked, err := server.denylist.IsRevoked(context.Background(), accessPayload)
+			require.NoError(t, err)
+
+			tc.checkResponse(t, recorder, revoked)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/google/uuid"
+)
+
+const (
+	// personalAccessTokenPrefix tells personal access tokens apart from the
+	// login tokens and makes them easy to find by secret scanners
+	personalAccessTokenPrefix = "dvt_"
+	// personalAccessTokenSize is the number of random bytes of a personal access token
+	personalAccessTokenSize = 32
+	// lastUsedInterval is how often the last use of a personal access token is
+	// saved, so every request doesn't write to the database
+	lastUsedInterval = time.Minute
+)
+
+// Scopes a personal access token can be granted. Each route declares the
+// scopes it needs in setupRouter, scoped tokens can't reach the routes without
+// scopes
+const (
+	scopeTasksRead     = "tasks:read"
+	scopeTasksWrite    = "tasks:write"
+	scopeProjectsRead  = "projects:read"
+	scopeProjectsWrite = "projects:write"
+	scopeTagsRead      = "tags:read"
+	scopeTagsWrite     = "tags:write"
+	scopeUserRead      = "user:read"
+)
+
+var availableScopes = []string{
+	scopeTasksRead, scopeTasksWrite,
+	scopeProjectsRead, scopeProjectsWrite,
+	scopeTagsRead, scopeTagsWrite,
+	scopeUserRead,
+}
+
+type personalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newPersonalAccessTokenResponse(pat db.PersonalAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		ID:         pat.ID,
+		Name:       pat.Name,
+		Scopes:     pat.Scopes,
+		ExpiresAt:  pat.ExpiresAt,
+		LastUsedAt: pat.LastUsedAt,
+		CreatedAt:  pat.CreatedAt,
+	}
+}
+
+type createPersonalAccessTokenRequest struct {
+	Name      string     `json:"name" requirements:"required;max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type createPersonalAccessTokenResponse struct {
+	Token               string                      `json:"token"`
+	PersonalAccessToken personalAccessTokenResponse `json:"personal_access_token"`
+}
+
+// createPersonalAccessToken creates a long lived token of the authenticated user
+// for scripts. Only its hash is stored so the token is shown just this once, and
+// like every other token it stops working when the password changes
+func (server *Server) createPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req createPersonalAccessTokenRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		writeError(w, fieldError("scopes", err))
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeError(w, fieldError("expires_at", fmt.Errorf("expires_at must be in the future")))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	secret, err := util.SecureToken(personalAccessTokenSize)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+	tokenString := personalAccessTokenPrefix + secret
+
+	arg := db.CreatePersonalAccessTokenParams{
+		ID:        id,
+		Username:  payload.Username,
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: util.HashToken(tokenString),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	pat, err := server.store.CreatePersonalAccessToken(r.Context(), arg)
+	if err != nil {
+		if db.ErrorCode(err) == db.UniqueViolation {
+			writeError(w, newAPIError(http.StatusForbidden, codeAlreadyExists, "a token with that name already exists"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := createPersonalAccessTokenResponse{
+		Token:               tokenString,
+		PersonalAccessToken: newPersonalAccessTokenResponse(pat),
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse(res))
+}
+
+// listPersonalAccessTokens lists the personal access tokens of the authenticated
+// user, the tokens themselves can't be read again
+func (server *Server) listPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	pats, err := server.store.ListPersonalAccessTokens(r.Context(), payload.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := make([]personalAccessTokenResponse, len(pats))
+	for i, pat := range pats {
+		res[i] = newPersonalAccessTokenResponse(pat)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+type deletePersonalAccessTokenRequest struct {
+	ID string `json:"id" requirements:"required"`
+}
+
+// deletePersonalAccessToken revokes a personal access token of the authenticated user
+func (server *Server) deletePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req deletePersonalAccessTokenRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		writeError(w, fieldError("id", err))
+		return
+	}
+
+	pat, err := server.store.GetPersonalAccessToken(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("token"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	if payload.Username != pat.Username {
+		writeError(w, accessDeniedError("token"))
+		return
+	}
+
+	err = server.store.DeletePersonalAccessToken(r.Context(), pat.ID)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyPersonalAccessToken looks up a personal access token and checks that it
+// grants every scope of the route, writing an error when it doesn't. The
+// returned payload lets the handlers treat it like any other access token
+func (server *Server) verifyPersonalAccessToken(w http.ResponseWriter, r *http.Request, tokenString string, scopes []string) (*token.Payload, bool) {
+	pat, err := server.store.GetPersonalAccessTokenByHash(r.Context(), util.HashToken(tokenString))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, tokenError(token.ErrInvalidToken))
+			return nil, false
+		}
+		writeError(w, internalError(r, err))
+		return nil, false
+	}
+
+	if pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt) {
+		writeError(w, tokenError(token.ErrExpiredToken))
+		return nil, false
+	}
+
+	payload := &token.Payload{
+		ID:       pat.ID,
+		Username: pat.Username,
+		Type:     token.TokenTypeAccessToken,
+		Scopes:   pat.Scopes,
+		IssuedAt: pat.CreatedAt,
+	}
+	if pat.ExpiresAt != nil {
+		payload.ExpiresAt = *pat.ExpiresAt
+	}
+	// personal access tokens are always scoped, so they never get full access
+	if payload.Scopes == nil {
+		payload.Scopes = []string{}
+	}
+
+	if !requireScopes(w, payload, scopes) {
+		return nil, false
+	}
+
+	if pat.LastUsedAt == nil || time.Since(*pat.LastUsedAt) > lastUsedInterval {
+		err = server.store.TouchPersonalAccessToken(r.Context(), pat.ID)
+		if err != nil {
+			writeError(w, internalError(r, err))
+			return nil, false
+		}
+	}
+
+	return payload, true
+}
+
+// normalizeScopes validates the requested scopes and returns them sorted and
+// without repetitions
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required, available scopes are %s", strings.Join(availableScopes, ", "))
+	}
+
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(availableScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q, available scopes are %s", scope, strings.Join(availableScopes, ", "))
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+
+	slices.Sort(normalized)
+	return normalized, nil
+}
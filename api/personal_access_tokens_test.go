@@ -0,0 +1,547 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPersonalAccessToken(username string, scopes ...string) (db.PersonalAccessToken, string) {
+	tokenString := personalAccessTokenPrefix + util.RandomPassword(43)
+	return db.PersonalAccessToken{
+		ID:        uuid.New(),
+		Username:  username,
+		Name:      util.RandomUsername(),
+		TokenHash: util.HashToken(tokenString),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}, tokenString
+}
+
+func TestCreatePersonalAccessTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"name":       "ci",
+				"scopes":     []string{"tasks:write", "TASKS:READ", "tasks:write"},
+				"expires_at": expiresAt,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreatePersonalAccessTokenParams) (db.PersonalAccessToken, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "ci", arg.Name)
+						require.Equal(t, []string{scopeTasksRead, scopeTasksWrite}, arg.Scopes)
+						require.NotNil(t, arg.ExpiresAt)
+						require.True(t, expiresAt.Equal(*arg.ExpiresAt))
+						return db.PersonalAccessToken{
+							ID:        arg.ID,
+							Username:  arg.Username,
+							Name:      arg.Name,
+							TokenHash: arg.TokenHash,
+							Scopes:    arg.Scopes,
+							ExpiresAt: arg.ExpiresAt,
+							CreatedAt: time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res createPersonalAccessTokenResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.True(t, strings.HasPrefix(res.Token, personalAccessTokenPrefix))
+				require.NotZero(t, res.PersonalAccessToken.ID)
+				require.Equal(t, "ci", res.PersonalAccessToken.Name)
+				require.NotContains(t, recorder.Body.String(), "token_hash")
+			},
+		},
+		{
+			name: "NoScopes",
+			body: map[string]any{
+				"name": "ci",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "UnknownScope",
+			body: map[string]any{
+				"name":   "ci",
+				"scopes": []string{"tasks:read", "admin"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "ExpiresInThePast",
+			body: map[string]any{
+				"name":       "ci",
+				"scopes":     []string{"tasks:read"},
+				"expires_at": time.Now().Add(-time.Hour),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "DuplicatedName",
+			body: map[string]any{
+				"name":   "ci",
+				"scopes": []string{"tasks:read"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PersonalAccessToken{}, db.ErrUniqueViolation)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAlreadyExists)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"name":   "ci",
+				"scopes": []string{"tasks:read"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PersonalAccessToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "PersonalAccessToken",
+			body: map[string]any{
+				"name":   "ci",
+				"scopes": []string{"tasks:read"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, "Bearer "+personalAccessTokenPrefix+"secret")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// a personal access token can't create more tokens
+				pat, _ := randomPersonalAccessToken(user.Username, availableScopes...)
+				store.EXPECT().
+					GetPersonalAccessTokenByHash(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(pat, nil)
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInsufficientScope)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: map[string]any{
+				"name":   "ci",
+				"scopes": []string{"tasks:read"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/tokens"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListPersonalAccessTokensAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 3
+	pats := make([]db.PersonalAccessToken, n)
+	for i := range pats {
+		pats[i], _ = randomPersonalAccessToken(user.Username, scopeTasksRead)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListPersonalAccessTokens(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(pats, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/users/tokens", nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var res []personalAccessTokenResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &res)
+	require.NoError(t, err)
+	require.Len(t, res, n)
+	for i, pat := range pats {
+		require.Equal(t, pat.ID, res[i].ID)
+		require.Equal(t, pat.Name, res[i].Name)
+	}
+	require.NotContains(t, recorder.Body.String(), pats[0].TokenHash)
+}
+
+func TestDeletePersonalAccessTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	pat, _ := randomPersonalAccessToken(user.Username, scopeTasksRead)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"id": pat.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(pat, nil)
+				store.EXPECT().
+					DeletePersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			body: map[string]any{
+				"id": pat.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(db.PersonalAccessToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					DeletePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "token_not_found")
+			},
+		},
+		{
+			name: "AnotherUser",
+			body: map[string]any{
+				"id": pat.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				other, _ := randomPersonalAccessToken(util.RandomUsername(), scopeTasksRead)
+				other.ID = pat.ID
+
+				store.EXPECT().
+					GetPersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(other, nil)
+				store.EXPECT().
+					DeletePersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAccessDenied)
+			},
+		},
+		{
+			name: "InvalidID",
+			body: map[string]any{
+				"id": "not-an-uuid",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"id": pat.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(pat, nil)
+				store.EXPECT().
+					DeletePersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/tokens"
+			request, err := http.NewRequest(http.MethodDelete, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAuthMiddlewarePersonalAccessToken(t *testing.T) {
+	username := util.RandomUsername()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore, pat db.PersonalAccessToken) db.PersonalAccessToken
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore, pat db.PersonalAccessToken) db.PersonalAccessToken {
+				store.EXPECT().
+					TouchPersonalAccessToken(gomock.Any(), gomock.Eq(pat.ID)).
+					Times(1).
+					Return(nil)
+				return pat
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, username, recorder.Body.String())
+			},
+		},
+		{
+			name: "RecentlyUsed",
+			buildStubs: func(store *mockdb.MockStore, pat db.PersonalAccessToken) db.PersonalAccessToken {
+				lastUsedAt := time.Now().Add(-time.Second)
+				pat.LastUsedAt = &lastUsedAt
+				store.EXPECT().
+					TouchPersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+				return pat
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "MissingScope",
+			buildStubs: func(store *mockdb.MockStore, pat db.PersonalAccessToken) db.PersonalAccessToken {
+				pat.Scopes = []string{scopeTasksRead}
+				store.EXPECT().
+					TouchPersonalAccessToken(gomock.Any(), gomock.Any()).
+					Times(0)
+				return pat
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInsufficientScope)
+			},
+		},
+		{
+			name: "Expired",
+			buildStubs: func(store *mockdb.MockStore, pat db.PersonalAccessToken) db.PersonalAccessToken {
+				expiresAt := time.Now().Add(-time.Minute)
+				pat.ExpiresAt = &expiresAt
+				return pat
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTokenExpired)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pat, tokenString := randomPersonalAccessToken(username, scopeTasksRead, scopeTasksWrite)
+
+			store := mockdb.NewMockStore(ctrl)
+			pat = tc.buildStubs(store, pat)
+			store.EXPECT().
+				GetPersonalAccessTokenByHash(gomock.Any(), gomock.Eq(util.HashToken(tokenString))).
+				Times(1).
+				Return(pat, nil)
+
+			server := newTestServer(t, store)
+			authPath := "/auth"
+			server.router.HandleFunc(authPath, server.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				payload, valid := requirePayload(w, r)
+				if !valid {
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(payload.Username))
+			}, scopeTasksRead, scopeTasksWrite))
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, "Bearer "+tokenString)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAuthMiddlewareUnknownPersonalAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetPersonalAccessTokenByHash(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.PersonalAccessToken{}, sql.ErrNoRows)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/tasks", nil)
+	require.NoError(t, err)
+
+	request.Header.Set(authorizationHeaderKey, "Bearer "+personalAccessTokenPrefix+"unknown")
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTokenInvalid)
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+type createProjectRequest struct {
+	Name  string `json:"name" requirements:"required;max=100"`
+	Color string `json:"color" requirements:"max=20"`
+}
+
+func (server *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var req createProjectRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	arg := db.CreateProjectParams{
+		Owner: payload.Username,
+		Name:  req.Name,
+		Color: req.Color,
+	}
+
+	project, err := server.store.CreateProject(r.Context(), arg)
+	if err != nil {
+		if db.ErrorCode(err) == db.ForeignKeyViolation {
+			writeError(w, invalidReferenceError())
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse(project))
+}
+
+type listProjectsRequest struct {
+	Offset   int32 `form:"offset" requirements:"min=0"`
+	Limit    int32 `form:"limit" requirements:"min=1"`
+	Archived bool  `form:"archived"`
+}
+
+func (server *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	var req listProjectsRequest
+	err := util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	arg := db.ListProjectsParams{
+		Owner:    payload.Username,
+		Archived: req.Archived,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	}
+
+	projects, err := server.store.ListProjects(r.Context(), arg)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(projects))
+}
+
+type getProjectRequest struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+func (server *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	var req getProjectRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	project, valid := server.validProject(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(project))
+}
+
+type updateProjectRequest struct {
+	ID       int64  `json:"id" requirements:"min=1"`
+	Name     string `json:"name" requirements:"required;max=100"`
+	Color    string `json:"color" requirements:"max=20"`
+	Archived bool   `json:"archived"`
+}
+
+func (server *Server) updateProject(w http.ResponseWriter, r *http.Request) {
+	var req updateProjectRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	_, valid := server.validProject(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	arg := db.UpdateProjectParams{
+		ID:       req.ID,
+		Name:     req.Name,
+		Color:    req.Color,
+		Archived: req.Archived,
+	}
+
+	project, err := server.store.UpdateProject(r.Context(), arg)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(project))
+}
+
+type deleteProjectRequest struct {
+	ID int64 `json:"id" requirements:"min=1"`
+}
+
+func (server *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
+	var req deleteProjectRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	_, valid := server.validProject(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	err = server.store.DeleteProject(r.Context(), req.ID)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type listProjectTasksUri struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+func (server *Server) listProjectTasks(w http.ResponseWriter, r *http.Request) {
+	var uri listProjectTasksUri
+	err := util.ShouldBindUri(r, &uri)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	var req listTasksRequest
+	err = util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	sorts, err := db.ParseTaskSort(req.Sort)
+	if err != nil {
+		writeError(w, fieldError("sort", err))
+		return
+	}
+
+	project, valid := server.validProject(w, r, uri.ID)
+	if !valid {
+		return
+	}
+
+	arg := req.listTasksParams(project.Owner)
+	arg.ProjectID = &project.ID
+
+	tasks, err := server.store.ListTasksSorted(r.Context(), arg, sorts)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	rsp, err := server.newTaskListResponse(r.Context(), tasks)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(rsp))
+}
+
+type moveProjectTasksUri struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+type moveProjectTasksRequest struct {
+	TaskIDs []int64 `json:"task_ids"`
+}
+
+func (server *Server) moveProjectTasks(w http.ResponseWriter, r *http.Request) {
+	var uri moveProjectTasksUri
+	err := util.ShouldBindUri(r, &uri)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	var req moveProjectTasksRequest
+	err = util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	project, valid := server.validProject(w, r, uri.ID)
+	if !valid {
+		return
+	}
+
+	arg := db.MoveTasksParams{
+		ProjectID: &project.ID,
+		Owner:     project.Owner,
+		Ids:       req.TaskIDs,
+	}
+
+	tasks, err := server.store.MoveTasksTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrTaskNotFound {
+			writeError(w, newAPIError(http.StatusBadRequest, codeTaskNotFound, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(tasks))
+}
+
+// validProject gets the project and checks that it belongs to the authenticated user
+func (server *Server) validProject(w http.ResponseWriter, r *http.Request, id int64) (db.Project, bool) {
+	project, err := server.store.GetProject(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("project"))
+			return db.Project{}, false
+		}
+		writeError(w, internalError(r, err))
+		return db.Project{}, false
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return db.Project{}, false
+	}
+
+	if payload.Username != project.Owner {
+		writeError(w, accessDeniedError("project"))
+		return db.Project{}, false
+	}
+
+	return project, true
+}
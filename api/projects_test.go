@@ -0,0 +1,896 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateProjectAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{"name": project.Name, "color": project.Color},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateProjectParams{
+					Owner: user.Username,
+					Name:  project.Name,
+					Color: project.Color,
+				}
+				store.EXPECT().
+					CreateProject(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(project, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				requireBodyMatchProject(t, recorder.Body, project)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{"name": project.Name, "color": project.Color},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateProject(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Project{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidName",
+			body: map[string]any{"name": "", "color": project.Color},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateProject(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: map[string]any{"name": project.Name, "color": project.Color},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateProject(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/projects"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetProjectAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+
+	testCases := []struct {
+		name          string
+		projectID     int64
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			projectID: project.ID,
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetProject(gomock.Any(), gomock.Eq(project.ID)).
+					Times(1).
+					Return(project, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchProject(t, recorder.Body, project)
+			},
+		},
+		{
+			name:      "NotFound",
+			projectID: project.ID,
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetProject(gomock.Any(), gomock.Eq(project.ID)).
+					Times(1).
+					Return(db.Project{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "project_not_found")
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			projectID: project.ID,
+			username:  "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetProject(gomock.Any(), gomock.Eq(project.ID)).
+					Times(1).
+					Return(project, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "InternalServerError",
+			projectID: project.ID,
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetProject(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Project{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidID",
+			projectID: 0,
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetProject(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/projects/%d", tc.projectID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListProjectsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 5
+	projects := make([]db.Project, n)
+	for i := 0; i < n; i++ {
+		projects[i] = randomProject(user.Username)
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: fmt.Sprintf("offset=0&limit=%d", n),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListProjectsParams{
+					Owner:  user.Username,
+					Offset: 0,
+					Limit:  int32(n),
+				}
+				store.EXPECT().
+					ListProjects(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(projects, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchProjects(t, recorder.Body, projects)
+			},
+		},
+		{
+			name:  "OKArchived",
+			query: fmt.Sprintf("offset=0&limit=%d&archived=true", n),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListProjectsParams{
+					Owner:    user.Username,
+					Archived: true,
+					Offset:   0,
+					Limit:    int32(n),
+				}
+				store.EXPECT().
+					ListProjects(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(projects, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchProjects(t, recorder.Body, projects)
+			},
+		},
+		{
+			name:  "InternalServerError",
+			query: fmt.Sprintf("offset=0&limit=%d", n),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListProjects(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Project{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidLimit",
+			query: "offset=0&limit=0",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListProjects(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidArchived",
+			query: fmt.Sprintf("offset=0&limit=%d&archived=maybe", n),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListProjects(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/projects?" + tc.query
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateProjectAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project1 := randomProject(user.Username)
+	project2 := randomProject(user.Username)
+	project2.ID = project1.ID
+	project2.Archived = true
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"id":       project2.ID,
+				"name":     project2.Name,
+				"color":    project2.Color,
+				"archived": project2.Archived,
+			},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateProjectParams{
+					ID:       project2.ID,
+					Name:     project2.Name,
+					Color:    project2.Color,
+					Archived: project2.Archived,
+				}
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project1.ID)).Times(1).Return(project1, nil)
+				store.EXPECT().UpdateProject(gomock.Any(), gomock.Eq(arg)).Times(1).Return(project2, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchProject(t, recorder.Body, project2)
+			},
+		},
+		{
+			name: "NotFound",
+			body: map[string]any{
+				"id":   project2.ID,
+				"name": project2.Name,
+			},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(1).Return(db.Project{}, sql.ErrNoRows)
+				store.EXPECT().UpdateProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			body: map[string]any{
+				"id":   project2.ID,
+				"name": project2.Name,
+			},
+			username: "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project1.ID)).Times(1).Return(project1, nil)
+				store.EXPECT().UpdateProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"id":   project2.ID,
+				"name": project2.Name,
+			},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project1.ID)).Times(1).Return(project1, nil)
+				store.EXPECT().UpdateProject(gomock.Any(), gomock.Any()).Times(1).Return(db.Project{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidName",
+			body: map[string]any{
+				"id":   project2.ID,
+				"name": "",
+			},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/projects"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteProjectAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			body:     map[string]any{"id": project.ID},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().DeleteProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			body:     map[string]any{"id": project.ID},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(1).Return(db.Project{}, sql.ErrNoRows)
+				store.EXPECT().DeleteProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			body:     map[string]any{"id": project.ID},
+			username: "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().DeleteProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidID",
+			body:     map[string]any{"id": 0},
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DeleteProject(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/projects"
+			request, err := http.NewRequest(http.MethodDelete, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListProjectTasksAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+
+	n := 5
+	tasks := make([]db.Task, n)
+	for i := 0; i < n; i++ {
+		tasks[i] = randomTask(user.Username)
+		tasks[i].ProjectID = &project.ID
+	}
+
+	testCases := []struct {
+		name          string
+		projectID     int64
+		query         string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			projectID: project.ID,
+			query:     fmt.Sprintf("offset=0&limit=%d", n),
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListTasksParams{
+					Owner:     user.Username,
+					ProjectID: &project.ID,
+					Offset:    0,
+					Limit:     int32(n),
+				}
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Eq(arg), gomock.Nil()).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Eq(taskIDs(tasks))).
+					Times(1).
+					Return([]db.ListTaskProgressRow{{ParentID: tasks[0].ID, Total: 2, Done: 1}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []taskResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp, n)
+				for i, task := range rsp {
+					require.Equal(t, tasks[i], task.Task)
+				}
+				require.NotNil(t, rsp[0].Progress)
+				require.Equal(t, 0.5, *rsp[0].Progress)
+				require.Nil(t, rsp[1].Progress)
+			},
+		},
+		{
+			name:      "OKSort",
+			projectID: project.ID,
+			query:     fmt.Sprintf("offset=0&limit=%d&sort=-priority", n),
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				sorts := []db.TaskSort{{Field: "priority", Desc: true}}
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Eq(sorts)).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name:      "NotFound",
+			projectID: project.ID,
+			query:     fmt.Sprintf("offset=0&limit=%d", n),
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(1).Return(db.Project{}, sql.ErrNoRows)
+				store.EXPECT().ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			projectID: project.ID,
+			query:     fmt.Sprintf("offset=0&limit=%d", n),
+			username:  "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "InternalServerError",
+			projectID: project.ID,
+			query:     fmt.Sprintf("offset=0&limit=%d", n),
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Task{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidLimit",
+			projectID: project.ID,
+			query:     "offset=0&limit=0",
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidID",
+			projectID: 0,
+			query:     fmt.Sprintf("offset=0&limit=%d", n),
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/projects/%d/tasks?%s", tc.projectID, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestMoveProjectTasksAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	project := randomProject(user.Username)
+
+	n := 3
+	tasks := make([]db.Task, n)
+	taskIDs := make([]int64, n)
+	for i := 0; i < n; i++ {
+		tasks[i] = randomTask(user.Username)
+		tasks[i].ProjectID = &project.ID
+		taskIDs[i] = tasks[i].ID
+	}
+
+	testCases := []struct {
+		name          string
+		projectID     int64
+		body          map[string]any
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			projectID: project.ID,
+			body:      map[string]any{"task_ids": taskIDs},
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.MoveTasksParams{
+					ProjectID: &project.ID,
+					Owner:     user.Username,
+					Ids:       taskIDs,
+				}
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					MoveTasksTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(tasks, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name:      "ForeignTasks",
+			projectID: project.ID,
+			body:      map[string]any{"task_ids": taskIDs},
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					MoveTasksTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, db.ErrTaskNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTaskNotFound)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			projectID: project.ID,
+			body:      map[string]any{"task_ids": taskIDs},
+			username:  "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().MoveTasksTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "InternalServerError",
+			projectID: project.ID,
+			body:      map[string]any{"task_ids": taskIDs},
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					MoveTasksTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidID",
+			projectID: 0,
+			body:      map[string]any{"task_ids": taskIDs},
+			username:  user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().MoveTasksTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/projects/%d/tasks", tc.projectID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func randomProject(owner string) db.Project {
+	return db.Project{
+		ID:    util.RandomID(),
+		Owner: owner,
+		Name:  util.RandomPassword(10),
+		Color: "#" + util.RandomPassword(6),
+	}
+}
+
+func requireBodyMatchProject(t *testing.T, body *bytes.Buffer, project db.Project) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotProject db.Project
+	err = json.Unmarshal(data, &gotProject)
+	require.NoError(t, err)
+	require.Equal(t, project, gotProject)
+}
+
+func requireBodyMatchProjects(t *testing.T, body *bytes.Buffer, projects []db.Project) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotProjects []db.Project
+	err = json.Unmarshal(data, &gotProjects)
+	require.NoError(t, err)
+	require.Equal(t, projects, gotProjects)
+}
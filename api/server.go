@@ -1,13 +1,21 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/denylist"
+	"github.com/DMV-Nicolas/DevoraTasks/mail"
+	"github.com/DMV-Nicolas/DevoraTasks/throttle"
 	"github.com/DMV-Nicolas/DevoraTasks/token"
 	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/DMV-Nicolas/DevoraTasks/verifier"
 	"github.com/gorilla/mux"
 )
 
@@ -15,11 +23,31 @@ type Server struct {
 	config     util.Config
 	store      db.Store
 	tokenMaker token.Maker
-	router     *mux.Router
+	denylist   denylist.Denylist
+	mailer     mail.Mailer
+	// loginUsers and loginIPs count the failed logins of each username and client ip
+	loginUsers *throttle.Throttle
+	loginIPs   *throttle.Throttle
+	// resetEmails and resetIPs count the password reset requests of each email and client ip
+	resetEmails *throttle.Throttle
+	resetIPs    *throttle.Throttle
+	router      *mux.Router
+	// background tracks the work that handlers leave running after they respond
+	background sync.WaitGroup
 }
 
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := mail.NewMailer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dummyPasswordHash()
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +56,17 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 		config:     config,
 		store:      store,
 		tokenMaker: tokenMaker,
+		denylist: denylist.NewCachedDenylist(
+			denylist.NewStoreDenylist(store),
+			config.DenylistCacheSize,
+			config.DenylistCacheTTL,
+		),
+		mailer:     mailer,
+		loginUsers: throttle.New(config.LoginMaxAttempts, config.LoginLockoutDuration, config.LoginMaxLockout),
+		loginIPs:   throttle.New(config.LoginMaxAttemptsPerIP, config.LoginLockoutDuration, config.LoginMaxLockout),
+		// the requests are spaced out with the same lockouts as the failed logins
+		resetEmails: throttle.New(config.PasswordResetMax, config.LoginLockoutDuration, config.LoginMaxLockout),
+		resetIPs:    throttle.New(config.PasswordResetMaxPerIP, config.LoginLockoutDuration, config.LoginMaxLockout),
 	}
 
 	server.setupRouter()
@@ -37,39 +76,165 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 
 func (server *Server) setupRouter() {
 	router := mux.NewRouter()
+	router.Use(recoveryMiddleware)
 	router.Use(corsMiddleware)
+	router.Use(server.timeoutMiddleware)
 	router.HandleFunc("/", Home).Methods("GET")
+	router.HandleFunc(verifier.KeysPath, server.listPasetoKeys).Methods("GET")
 
 	router.HandleFunc("/users", server.createUser).Methods("POST")
 	router.HandleFunc("/users/login", server.loginUser).Methods("POST")
-	router.HandleFunc("/users", authMiddleware(server.getUser, server.tokenMaker)).Methods("GET")
-
-	router.HandleFunc("/tasks", authMiddleware(server.createTask, server.tokenMaker)).Methods("POST")
-	router.HandleFunc("/tasks", authMiddleware(server.listTasks, server.tokenMaker)).Methods("GET")
-	router.HandleFunc("/tasks/{id}", authMiddleware(server.getTask, server.tokenMaker)).Methods("GET")
-	router.HandleFunc("/tasks", authMiddleware(server.updateTask, server.tokenMaker)).Methods("PUT")
-	router.HandleFunc("/tasks", authMiddleware(server.deleteTask, server.tokenMaker)).Methods("DELETE")
+	router.HandleFunc("/users/login/mfa", server.loginUserMFA).Methods("POST")
+	router.HandleFunc("/users/verify_email", server.verifyEmail).Methods("GET")
+	router.HandleFunc("/users/logout", server.authMiddleware(server.logoutUser)).Methods("POST")
+	router.HandleFunc("/users/logout_all", server.authMiddleware(server.logoutAllUserSessions)).Methods("POST")
+	router.HandleFunc("/users", server.authMiddleware(server.getUser, scopeUserRead)).Methods("GET")
+	router.HandleFunc("/users", server.authMiddleware(server.updateUser)).Methods("PATCH")
+	router.HandleFunc("/users", server.authMiddleware(server.deleteUser)).Methods("DELETE")
+	router.HandleFunc("/users/export", server.authMiddleware(server.exportUser)).Methods("GET")
+	router.HandleFunc("/users/password", server.authMiddleware(server.changeUserPassword)).Methods("PUT")
+	router.HandleFunc("/users/password/forgot", server.forgotPassword).Methods("POST")
+	router.HandleFunc("/users/password/reset", server.resetPassword).Methods("POST")
+	router.HandleFunc("/users/tokens", server.authMiddleware(server.createPersonalAccessToken)).Methods("POST")
+	router.HandleFunc("/users/tokens", server.authMiddleware(server.listPersonalAccessTokens)).Methods("GET")
+	router.HandleFunc("/users/tokens", server.authMiddleware(server.deletePersonalAccessToken)).Methods("DELETE")
+	router.HandleFunc("/users/mfa/totp", server.authMiddleware(server.enrollTotp)).Methods("POST")
+	router.HandleFunc("/users/mfa/totp/confirm", server.authMiddleware(server.confirmTotp)).Methods("POST")
+
+	router.HandleFunc("/tokens/renew_access", server.renewAccessToken).Methods("POST")
+
+	router.HandleFunc("/tasks", server.authMiddleware(server.createTask, scopeTasksWrite)).Methods("POST")
+	router.HandleFunc("/tasks", server.authMiddleware(server.listTasks, scopeTasksRead)).Methods("GET")
+	router.HandleFunc("/tasks/{id}", server.authMiddleware(server.getTask, scopeTasksRead)).Methods("GET")
+	router.HandleFunc("/tasks", server.authMiddleware(server.updateTask, scopeTasksWrite)).Methods("PUT")
+	router.HandleFunc("/tasks", server.authMiddleware(server.deleteTask, scopeTasksWrite)).Methods("DELETE")
+	router.HandleFunc("/tasks/{id}/tags", server.authMiddleware(server.setTaskTags, scopeTasksWrite)).Methods("PUT")
+	router.HandleFunc("/tasks/{id}/subtasks", server.authMiddleware(server.listSubtasks, scopeTasksRead)).Methods("GET")
+
+	router.HandleFunc("/projects", server.authMiddleware(server.createProject, scopeProjectsWrite)).Methods("POST")
+	router.HandleFunc("/projects", server.authMiddleware(server.listProjects, scopeProjectsRead)).Methods("GET")
+	router.HandleFunc("/projects/{id}", server.authMiddleware(server.getProject, scopeProjectsRead)).Methods("GET")
+	router.HandleFunc("/projects", server.authMiddleware(server.updateProject, scopeProjectsWrite)).Methods("PUT")
+	router.HandleFunc("/projects", server.authMiddleware(server.deleteProject, scopeProjectsWrite)).Methods("DELETE")
+	router.HandleFunc("/projects/{id}/tasks", server.authMiddleware(server.listProjectTasks, scopeProjectsRead, scopeTasksRead)).Methods("GET")
+	router.HandleFunc("/projects/{id}/tasks", server.authMiddleware(server.moveProjectTasks, scopeTasksWrite)).Methods("PUT")
+
+	router.HandleFunc("/tags", server.authMiddleware(server.createTag, scopeTagsWrite)).Methods("POST")
+	router.HandleFunc("/tags", server.authMiddleware(server.listTags, scopeTagsRead)).Methods("GET")
+	router.HandleFunc("/tags", server.authMiddleware(server.deleteTag, scopeTagsWrite)).Methods("DELETE")
+
+	router.HandleFunc("/admin/users", server.authMiddleware(roleMiddleware(server.adminListUsers, util.AdminRole))).Methods("GET")
+	router.HandleFunc("/admin/users/{username}/lock", server.authMiddleware(roleMiddleware(server.adminLockUser, util.AdminRole))).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/unlock", server.authMiddleware(roleMiddleware(server.adminUnlockUser, util.AdminRole))).Methods("POST")
+	router.HandleFunc("/admin/users/{username}", server.authMiddleware(roleMiddleware(server.adminDeleteUser, util.AdminRole))).Methods("DELETE")
+	router.HandleFunc("/admin/tasks", server.authMiddleware(roleMiddleware(server.adminListTasks, util.AdminRole))).Methods("GET")
+	router.HandleFunc("/admin/tasks/{id}", server.authMiddleware(roleMiddleware(server.adminGetTask, util.AdminRole))).Methods("GET")
+	router.HandleFunc("/admin/tasks/{id}", server.authMiddleware(roleMiddleware(server.adminDeleteTask, util.AdminRole))).Methods("DELETE")
 
 	server.router = router
 }
 
-func (server *Server) Start(address string) error {
-	return http.ListenAndServe(address, server.router)
+// Start serves the api on the address until ctx is done, then it stops taking
+// requests and waits for the ones in progress and the background work
+func (server *Server) Start(ctx context.Context, address string) error {
+	if server.config.DenylistCleanupEvery > 0 {
+		go server.cleanupDenylist(ctx, server.config.DenylistCleanupEvery)
+	}
+
+	httpServer := &http.Server{
+		Addr:    address,
+		Handler: server.router,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout())
+	defer cancel()
+
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+	return server.waitBackground(shutdownCtx)
+}
+
+// shutdownTimeout returns how long the server waits for the requests in
+// progress and the background work before it stops, the background jobs are
+// bounded by the database and the mail timeouts
+func (server *Server) shutdownTimeout() time.Duration {
+	return server.config.DBTimeout + server.mailTimeout()
 }
 
-func errorResponse(err1 error) []byte {
-	type res struct {
-		Error string `json:"error"`
+// cleanupDenylist deletes the revoked tokens that already expired every interval
+// until the context is done, expired tokens are rejected without the denylist
+func (server *Server) cleanupDenylist(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := server.store.DeleteExpiredRevokedTokens(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("cannot delete the expired revoked tokens: %v", err)
+			}
+		}
 	}
+}
 
-	newRes, err2 := json.Marshal(res{
-		Error: err1.Error(),
-	})
-	if err2 != nil {
-		log.Fatal("Cannot marshal the error response")
+// defaultMailTimeout bounds the emails when MAIL_TIMEOUT isn't set
+const defaultMailTimeout = 10 * time.Second
+
+// mailTimeout returns how long sending an email can take
+func (server *Server) mailTimeout() time.Duration {
+	if server.config.MailTimeout <= 0 {
+		return defaultMailTimeout
 	}
+	return server.config.MailTimeout
+}
 
-	return []byte(newRes)
+// runInBackground runs fn in its own goroutine. A panic in fn is logged instead
+// of taking down the server
+func (server *Server) runInBackground(fn func()) {
+	server.background.Add(1)
+	go func() {
+		defer server.background.Done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("panic in background task: %v\n%s", rec, debug.Stack())
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// waitBackground waits for the background work to finish, it gives up when ctx
+// is done
+func (server *Server) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		server.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func jsonResponse(res any) []byte {
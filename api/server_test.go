@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupDenylist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{})
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any()).
+		MinTimes(2).
+		DoAndReturn(func(_ context.Context) error {
+			calls <- struct{}{}
+			return sql.ErrConnDone
+		})
+
+	server := newTestServer(t, store)
+	done := make(chan struct{})
+	go func() {
+		server.cleanupDenylist(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	// a failed cleanup doesn't stop the next ones
+	<-calls
+	<-calls
+	cancel()
+
+	// drain a cleanup that started before the cancel so the loop can return
+	for {
+		select {
+		case <-calls:
+		case <-done:
+			return
+		}
+	}
+}
+
+func TestStartWaitsForBackground(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	release := make(chan struct{})
+	finished := false
+	server.runInBackground(func() {
+		<-release
+		finished = true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Start(ctx, "127.0.0.1:0")
+	}()
+
+	// the server can't stop while the background work is running
+	cancel()
+	select {
+	case err := <-errs:
+		t.Fatalf("server stopped before the background work: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.True(t, finished)
+}
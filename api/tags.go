@@ -0,0 +1,182 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+type createTagRequest struct {
+	Name string `json:"name" requirements:"required;max=50"`
+}
+
+func (server *Server) createTag(w http.ResponseWriter, r *http.Request) {
+	var req createTagRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	arg := db.CreateTagParams{
+		Owner: payload.Username,
+		Name:  req.Name,
+	}
+
+	tag, err := server.store.CreateTag(r.Context(), arg)
+	if err != nil {
+		switch db.ErrorCode(err) {
+		case db.UniqueViolation:
+			writeError(w, newAPIError(http.StatusForbidden, codeAlreadyExists, "tag already exists"))
+			return
+		case db.ForeignKeyViolation:
+			writeError(w, invalidReferenceError())
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResponse(tag))
+}
+
+type listTagsRequest struct {
+	Offset int32 `form:"offset" requirements:"min=0"`
+	Limit  int32 `form:"limit" requirements:"min=1"`
+}
+
+func (server *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	var req listTagsRequest
+	err := util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	arg := db.ListTagsParams{
+		Owner:  payload.Username,
+		Offset: req.Offset,
+		Limit:  req.Limit,
+	}
+
+	tags, err := server.store.ListTags(r.Context(), arg)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(tags))
+}
+
+type deleteTagRequest struct {
+	ID int64 `json:"id" requirements:"min=1"`
+}
+
+func (server *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	var req deleteTagRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	tag, err := server.store.GetTag(r.Context(), req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("tag"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	if payload.Username != tag.Owner {
+		writeError(w, accessDeniedError("tag"))
+		return
+	}
+
+	err = server.store.DeleteTag(r.Context(), req.ID)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type setTaskTagsUri struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+type setTaskTagsRequest struct {
+	TagIDs []int64 `json:"tag_ids"`
+}
+
+func (server *Server) setTaskTags(w http.ResponseWriter, r *http.Request) {
+	var uri setTaskTagsUri
+	err := util.ShouldBindUri(r, &uri)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	var req setTaskTagsRequest
+	err = util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	gotTask, valid := server.validTask(w, r, uri.ID)
+	if !valid {
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	if payload.Username != gotTask.Owner {
+		writeError(w, accessDeniedError("task"))
+		return
+	}
+
+	arg := db.SetTaskTagsTxParams{
+		TaskID: gotTask.ID,
+		Owner:  payload.Username,
+		TagIDs: req.TagIDs,
+	}
+
+	tags, err := server.store.SetTaskTagsTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrTagNotFound {
+			writeError(w, newAPIError(http.StatusBadRequest, codeTagNotFound, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(tags))
+}
@@ -3,82 +3,155 @@ package api
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"net/http"
+	"time"
 
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
-	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/recurrence"
 	"github.com/DMV-Nicolas/DevoraTasks/util"
-	ctx "github.com/gorilla/context"
 )
 
 type createTaskRequest struct {
-	Title       string `json:"title" requirements:"required"`
-	Description string `json:"description"`
+	Title       string     `json:"title" requirements:"required"`
+	Description string     `json:"description"`
+	DueAt       *time.Time `json:"due_at"`
+	Priority    string     `json:"priority" requirements:"oneof=low medium high urgent"`
+	ProjectID   *int64     `json:"project_id"`
+	ParentID    *int64     `json:"parent_id"`
+	Recurrence  string     `json:"recurrence"`
+	TagIDs      []int64    `json:"tag_ids"`
 }
 
 func (server *Server) createTask(w http.ResponseWriter, r *http.Request) {
 	var req createTaskRequest
 	err := util.ShouldBindJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
 		return
 	}
 
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
-	arg := db.CreateTaskParams{
-		Owner:       payload.Username,
-		Title:       req.Title,
-		Description: req.Description,
+	if !server.requireVerifiedEmail(w, r) {
+		return
+	}
+
+	priority := db.TaskPriority(req.Priority)
+	if priority == "" {
+		priority = db.TaskPriorityMedium
 	}
 
-	task, err := server.store.CreateTask(context.Background(), arg)
+	rule, err := normalizeRecurrence(req.Recurrence)
 	if err != nil {
+		writeError(w, fieldError("recurrence", err))
+		return
+	}
+
+	if req.ProjectID != nil {
+		_, valid := server.validProject(w, r, *req.ProjectID)
+		if !valid {
+			return
+		}
+	}
+
+	if req.ParentID != nil && !server.validParentTask(w, r, *req.ParentID) {
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	arg := db.CreateTaskTxParams{
+		CreateTaskParams: db.CreateTaskParams{
+			Owner:       payload.Username,
+			Title:       req.Title,
+			Description: req.Description,
+			DueAt:       req.DueAt,
+			Priority:    priority,
+			ProjectID:   req.ProjectID,
+			ParentID:    req.ParentID,
+			Recurrence:  rule,
+		},
+		TagIDs: req.TagIDs,
+	}
+
+	result, err := server.store.CreateTaskTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrTagNotFound {
+			writeError(w, newAPIError(http.StatusBadRequest, codeTagNotFound, err.Error()))
+			return
+		}
 		if db.ErrorCode(err) == db.ForeignKeyViolation {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write(errorResponse(err))
+			writeError(w, invalidReferenceError())
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	w.Write(jsonResponse(task))
+	w.Write(jsonResponse(result.Task))
 }
 
 type listTasksRequest struct {
-	Offset int32 `form:"offset" requirements:"min=0"`
-	Limit  int32 `form:"limit" requirements:"min=1"`
+	Offset    int32      `form:"offset" requirements:"min=0"`
+	Limit     int32      `form:"limit" requirements:"min=1"`
+	DueBefore *time.Time `form:"due_before"`
+	DueAfter  *time.Time `form:"due_after"`
+	Overdue   bool       `form:"overdue"`
+	Sort      string     `form:"sort"`
+	Tags      []string   `form:"tag"`
+	TagMode   string     `form:"tag_mode" requirements:"oneof=any all"`
+}
+
+func (req listTasksRequest) listTasksParams(owner string) db.ListTasksParams {
+	return db.ListTasksParams{
+		Owner:        owner,
+		DueBefore:    req.DueBefore,
+		DueAfter:     req.DueAfter,
+		Overdue:      req.Overdue,
+		Tags:         req.Tags,
+		MatchAllTags: req.TagMode == "all",
+		Offset:       req.Offset,
+		Limit:        req.Limit,
+	}
 }
 
 func (server *Server) listTasks(w http.ResponseWriter, r *http.Request) {
 	var req listTasksRequest
 	err := util.ShouldBindQuery(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
+		return
+	}
+
+	sorts, err := db.ParseTaskSort(req.Sort)
+	if err != nil {
+		writeError(w, fieldError("sort", err))
 		return
 	}
 
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
-	arg := db.ListTasksParams{
-		Owner:  payload.Username,
-		Offset: req.Offset,
-		Limit:  req.Limit,
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
 	}
 
-	tasks, err := server.store.ListTasks(context.Background(), arg)
+	arg := req.listTasksParams(payload.Username)
+
+	tasks, err := server.store.ListTasksSorted(r.Context(), arg, sorts)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	rsp, err := server.newTaskListResponse(r.Context(), tasks)
+	if err != nil {
+		writeError(w, internalError(r, err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write(jsonResponse(tasks))
+	w.Write(jsonResponse(rsp))
 }
 
 type getTaskRequest struct {
@@ -89,48 +162,60 @@ func (server *Server) getTask(w http.ResponseWriter, r *http.Request) {
 	var req getTaskRequest
 	err := util.ShouldBindUri(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
 		return
 	}
 
-	task, err := server.store.GetTask(context.Background(), req.ID)
+	task, err := server.store.GetTask(r.Context(), req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write(errorResponse(err))
+			writeError(w, notFoundError("task"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
 		return
 	}
 
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
 	if payload.Username != task.Owner {
-		err = fmt.Errorf("account doesn't belong to the authenticated user")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(errorResponse(err))
+		writeError(w, accessDeniedError("task"))
+		return
+	}
+
+	rsp, err := server.newTaskResponse(r.Context(), task)
+	if err != nil {
+		writeError(w, internalError(r, err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write(jsonResponse(task))
+	w.Write(jsonResponse(rsp))
 }
 
+// updateTaskRequest keeps the stored value of every optional field the client
+// doesn't send. due_at, project_id and parent_id are cleared by sending null
 type updateTaskRequest struct {
-	ID          int64  `json:"id" requirements:"min=1"`
-	Title       string `json:"title" requirements:"required"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	ID          int64                    `json:"id" requirements:"min=1"`
+	Title       string                   `json:"title" requirements:"required"`
+	Description *string                  `json:"description"`
+	Done        *bool                    `json:"done"`
+	DueAt       util.Nullable[time.Time] `json:"due_at"`
+	Priority    string                   `json:"priority" requirements:"oneof=low medium high urgent"`
+	ProjectID   util.Nullable[int64]     `json:"project_id"`
+	ParentID    util.Nullable[int64]     `json:"parent_id"`
+	Recurrence  *string                  `json:"recurrence"`
+	Cascade     bool                     `json:"cascade"`
 }
 
 func (server *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	var req updateTaskRequest
 	err := util.ShouldBindJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
 		return
 	}
 
@@ -139,31 +224,131 @@ func (server *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
 	if payload.Username != gotTask.Owner {
-		err = fmt.Errorf("account doesn't belong to the authenticated user")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(errorResponse(err))
+		writeError(w, accessDeniedError("task"))
 		return
 	}
 
-	arg := db.UpdateTaskParams{
-		ID:          req.ID,
-		Title:       req.Title,
-		Description: req.Description,
-		Done:        req.Done,
+	description := gotTask.Description
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	done := gotTask.Done
+	if req.Done != nil {
+		done = *req.Done
+	}
+
+	priority := db.TaskPriority(req.Priority)
+	if priority == "" {
+		priority = gotTask.Priority
+	}
+
+	// keep the recurrence when the client doesn't send it, so marking a task
+	// as done doesn't stop it from repeating
+	rule := gotTask.Recurrence
+	if req.Recurrence != nil {
+		rule, err = normalizeRecurrence(*req.Recurrence)
+		if err != nil {
+			writeError(w, fieldError("recurrence", err))
+			return
+		}
 	}
 
-	task, err := server.store.UpdateTask(context.Background(), arg)
+	if req.ProjectID.Value != nil {
+		_, valid := server.validProject(w, r, *req.ProjectID.Value)
+		if !valid {
+			return
+		}
+	}
+
+	if req.ParentID.Value != nil && !server.validParentTask(w, r, *req.ParentID.Value) {
+		return
+	}
+
+	arg := db.UpdateTaskTxParams{
+		UpdateTaskParams: db.UpdateTaskParams{
+			ID:          req.ID,
+			Title:       req.Title,
+			Description: description,
+			Done:        done,
+			DueAt:       req.DueAt.Or(gotTask.DueAt),
+			Priority:    priority,
+			ProjectID:   req.ProjectID.Or(gotTask.ProjectID),
+			ParentID:    req.ParentID.Or(gotTask.ParentID),
+			Recurrence:  rule,
+		},
+		CascadeDone: req.Cascade,
+	}
+
+	result, err := server.store.UpdateTaskTx(r.Context(), arg)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		if err == db.ErrTaskCycle {
+			writeError(w, newAPIError(http.StatusBadRequest, codeTaskCycle, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
 		return
 	}
 
+	rsp, err := server.newTaskResponse(r.Context(), result.Task)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+	rsp.NextOccurrence = result.NextTask
+
 	w.WriteHeader(http.StatusOK)
-	w.Write(jsonResponse(task))
+	w.Write(jsonResponse(rsp))
+
+}
+
+type listSubtasksRequest struct {
+	ID int64 `uri:"id" requirements:"min=1"`
+}
+
+func (server *Server) listSubtasks(w http.ResponseWriter, r *http.Request) {
+	var req listSubtasksRequest
+	err := util.ShouldBindUri(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
 
+	gotTask, valid := server.validTask(w, r, req.ID)
+	if !valid {
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	if payload.Username != gotTask.Owner {
+		writeError(w, accessDeniedError("task"))
+		return
+	}
+
+	tasks, err := server.store.ListSubtasks(r.Context(), gotTask.ID)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	rsp, err := server.newTaskListResponse(r.Context(), tasks)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(rsp))
 }
 
 type deleteTaskRequest struct {
@@ -174,8 +359,7 @@ func (server *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 	var req deleteTaskRequest
 	err := util.ShouldBindJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
 		return
 	}
 
@@ -184,18 +368,19 @@ func (server *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
 	if payload.Username != gotTask.Owner {
-		err = fmt.Errorf("account doesn't belong to the authenticated user")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(errorResponse(err))
+		writeError(w, accessDeniedError("task"))
 		return
 	}
 
-	err = server.store.DeleteTask(context.Background(), req.ID)
+	err = server.store.DeleteTask(r.Context(), req.ID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
@@ -203,17 +388,106 @@ func (server *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func (server *Server) validTask(w http.ResponseWriter, r *http.Request, id int64) (db.Task, bool) {
-	gotTask, err := server.store.GetTask(context.Background(), id)
+	gotTask, err := server.store.GetTask(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write(errorResponse(err))
+			writeError(w, notFoundError("task"))
 			return db.Task{}, false
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return db.Task{}, false
 	}
 
 	return gotTask, true
 }
+
+// validParentTask checks that the parent task exists and belongs to the authenticated user
+func (server *Server) validParentTask(w http.ResponseWriter, r *http.Request, id int64) bool {
+	parent, valid := server.validTask(w, r, id)
+	if !valid {
+		return false
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return false
+	}
+
+	if payload.Username != parent.Owner {
+		writeError(w, accessDeniedError("parent task"))
+		return false
+	}
+
+	return true
+}
+
+type taskResponse struct {
+	db.Task
+	Progress       *float64 `json:"progress,omitempty"`
+	NextOccurrence *db.Task `json:"next_occurrence,omitempty"`
+}
+
+// newTaskResponse adds the ratio of done subtasks to the task, tasks without subtasks have no progress
+func (server *Server) newTaskResponse(ctx context.Context, task db.Task) (taskResponse, error) {
+	progress, err := server.store.GetTaskProgress(ctx, task.ID)
+	if err != nil {
+		return taskResponse{}, err
+	}
+
+	rsp := taskResponse{Task: task}
+	if progress.Total > 0 {
+		ratio := float64(progress.Done) / float64(progress.Total)
+		rsp.Progress = &ratio
+	}
+
+	return rsp, nil
+}
+
+// newTaskListResponse adds the progress to every task of a list, the progress
+// of the whole list is read with a single query
+func (server *Server) newTaskListResponse(ctx context.Context, tasks []db.Task) ([]taskResponse, error) {
+	rsp := make([]taskResponse, len(tasks))
+	if len(tasks) == 0 {
+		return rsp, nil
+	}
+
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	rows, err := server.store.ListTaskProgress(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(map[int64]float64, len(rows))
+	for _, row := range rows {
+		if row.Total > 0 {
+			progress[row.ParentID] = float64(row.Done) / float64(row.Total)
+		}
+	}
+
+	for i, task := range tasks {
+		rsp[i] = taskResponse{Task: task}
+		if ratio, ok := progress[task.ID]; ok {
+			rsp[i].Progress = &ratio
+		}
+	}
+
+	return rsp, nil
+}
+
+// normalizeRecurrence validates a recurrence rule and returns its canonical form
+func normalizeRecurrence(rule string) (string, error) {
+	if rule == "" {
+		return "", nil
+	}
+
+	r, err := recurrence.Parse(rule)
+	if err != nil {
+		return "", err
+	}
+
+	return r.String(), nil
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -22,6 +23,9 @@ import (
 func TestCreateTaskAPI(t *testing.T) {
 	user, _ := randomUser(t)
 	task := randomTask(user.Username)
+	project := randomProject(user.Username)
+	parent := randomTask(user.Username)
+	tag := randomTag(user.Username)
 
 	testCases := []struct {
 		name          string
@@ -35,20 +39,26 @@ func TestCreateTaskAPI(t *testing.T) {
 			body: map[string]any{
 				"title":       task.Title,
 				"description": task.Description,
+				"due_at":      task.DueAt,
+				"priority":    task.Priority,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.CreateTaskParams{
-					Owner:       task.Owner,
-					Title:       task.Title,
-					Description: task.Description,
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						DueAt:       task.DueAt,
+						Priority:    task.Priority,
+					},
 				}
 				store.EXPECT().
-					CreateTask(gomock.Any(), gomock.Eq(arg)).
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(task, nil)
+					Return(db.CreateTaskTxResult{Task: task}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusCreated, recorder.Code)
@@ -66,9 +76,9 @@ func TestCreateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateTask(gomock.Any(), gomock.Any()).
+					CreateTaskTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.Task{}, sql.ErrConnDone)
+					Return(db.CreateTaskTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -85,9 +95,259 @@ func TestCreateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateTask(gomock.Any(), gomock.Any()).
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateTaskTxResult{}, db.ErrForeignKeyViolation)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OKProject",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"priority":    task.Priority,
+				"project_id":  project.ID,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						Priority:    task.Priority,
+						ProjectID:   &project.ID,
+					},
+				}
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.CreateTaskTxResult{Task: task}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				requireBodyMatchTask(t, recorder.Body, task)
+			},
+		},
+		{
+			name: "ForeignProject",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"project_id":  project.ID,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "pepito", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetProject(gomock.Any(), gomock.Eq(project.ID)).Times(1).Return(project, nil)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "OKParent",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"priority":    task.Priority,
+				"parent_id":   parent.ID,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						Priority:    task.Priority,
+						ParentID:    &parent.ID,
+					},
+				}
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.CreateTaskTxResult{Task: task}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				requireBodyMatchTask(t, recorder.Body, task)
+			},
+		},
+		{
+			name: "ParentNotFound",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"parent_id":   parent.ID,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(db.Task{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "OKRecurrence",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"priority":    task.Priority,
+				"recurrence":  "RRULE:FREQ=monthly;BYMONTHDAY=-1",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						Priority:    task.Priority,
+						Recurrence:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+					},
+				}
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.CreateTaskTxResult{Task: task}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidRecurrence",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"recurrence":  "FREQ=WEEKLY;BYDAY=XX",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OKTags",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"priority":    task.Priority,
+				"tag_ids":     []int64{tag.ID},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						Priority:    task.Priority,
+					},
+					TagIDs: []int64{tag.ID},
+				}
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.CreateTaskTxResult{Task: task, Tags: []db.Tag{tag}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				requireBodyMatchTask(t, recorder.Body, task)
+			},
+		},
+		{
+			name: "ForeignTags",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"tag_ids":     []int64{tag.ID},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateTaskTxResult{}, db.ErrTagNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "DefaultPriority",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateTaskTxParams{
+					CreateTaskParams: db.CreateTaskParams{
+						Owner:       task.Owner,
+						Title:       task.Title,
+						Description: task.Description,
+						Priority:    db.TaskPriorityMedium,
+					},
+				}
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(db.Task{}, db.ErrForeignKeyViolation)
+					Return(db.CreateTaskTxResult{Task: task}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidPriority",
+			body: map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+				"priority":    "whenever",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -104,7 +364,7 @@ func TestCreateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateTask(gomock.Any(), gomock.Any()).
+					CreateTaskTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -162,10 +422,36 @@ func TestGetTaskAPI(t *testing.T) {
 					GetTask(gomock.Any(), gomock.Eq(task.ID)).
 					Times(1).
 					Return(task, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Eq(task.ID)).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				requireBodyMatchTask(t, recorder.Body, task)
+				requireBodyMatchTaskResponse(t, recorder.Body, task, nil)
+			},
+		},
+		{
+			name:   "OKProgress",
+			taskID: task.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTask(gomock.Any(), gomock.Eq(task.ID)).
+					Times(1).
+					Return(task, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Eq(task.ID)).
+					Times(1).
+					Return(db.GetTaskProgressRow{Total: 4, Done: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				progress := 0.25
+				requireBodyMatchTaskResponse(t, recorder.Body, task, &progress)
 			},
 		},
 		{
@@ -182,6 +468,7 @@ func TestGetTaskAPI(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "task_not_found")
 			},
 		},
 		{
@@ -200,6 +487,22 @@ func TestGetTaskAPI(t *testing.T) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
+		{
+			name:   "Timeout",
+			taskID: task.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Task{}, context.DeadlineExceeded)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+			},
+		},
 		{
 			name:   "InvalidID",
 			taskID: 0,
@@ -213,6 +516,7 @@ func TestGetTaskAPI(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
 			},
 		},
 		{
@@ -223,215 +527,748 @@ func TestGetTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					GetTask(gomock.Any(), gomock.Eq(task.ID)).
+					GetTask(gomock.Any(), gomock.Eq(task.ID)).
+					Times(1).
+					Return(task, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAccessDenied)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/tasks/%d", tc.taskID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListTasksAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 5
+	tasks := make([]db.Task, n)
+	for i := 0; i < n; i++ {
+		tasks[i] = randomTask(user.Username)
+	}
+
+	type Query struct {
+		offset    int32
+		limit     int32
+		dueBefore string
+		dueAfter  string
+		overdue   string
+		sort      string
+		tags      []string
+		tagMode   string
+	}
+
+	dueBefore := time.Now().UTC().Truncate(time.Second).Add(24 * time.Hour)
+	dueAfter := dueBefore.Add(-48 * time.Hour)
+
+	testCases := []struct {
+		name          string
+		query         Query
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			query: Query{
+				offset: 0,
+				limit:  int32(n),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListTasksParams{
+					Owner:  user.Username,
+					Offset: 0,
+					Limit:  5,
+				}
+
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Eq(arg), gomock.Nil()).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name: "InternalServerError",
+			query: Query{
+				offset: 0,
+				limit:  int32(n),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Task{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "ProgressError",
+			query: Query{
+				offset: 0,
+				limit:  int32(n),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "OKDueFilters",
+			query: Query{
+				offset:    0,
+				limit:     int32(n),
+				dueBefore: dueBefore.Format(time.RFC3339),
+				dueAfter:  dueAfter.Format(time.RFC3339),
+				overdue:   "true",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListTasksParams{
+					Owner:     user.Username,
+					DueBefore: &dueBefore,
+					DueAfter:  &dueAfter,
+					Overdue:   true,
+					Offset:    0,
+					Limit:     5,
+				}
+
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Eq(arg), gomock.Nil()).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name: "OKSort",
+			query: Query{
+				offset: 0,
+				limit:  int32(n),
+				sort:   "-priority,due_at,created_at",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListTasksParams{
+					Owner:  user.Username,
+					Offset: 0,
+					Limit:  5,
+				}
+
+				sorts := []db.TaskSort{
+					{Field: "priority", Desc: true},
+					{Field: "due_at", Desc: false},
+					{Field: "created_at", Desc: false},
+				}
+
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Eq(arg), gomock.Eq(sorts)).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name: "OKTags",
+			query: Query{
+				offset:  0,
+				limit:   int32(n),
+				tags:    []string{"work", "urgent"},
+				tagMode: "all",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListTasksParams{
+					Owner:        user.Username,
+					Tags:         []string{"work", "urgent"},
+					MatchAllTags: true,
+					Offset:       0,
+					Limit:        5,
+				}
+
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Eq(arg), gomock.Nil()).
+					Times(1).
+					Return(tasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTasks(t, recorder.Body, tasks)
+			},
+		},
+		{
+			name: "InvalidTagMode",
+			query: Query{
+				offset:  0,
+				limit:   int32(n),
+				tags:    []string{"work"},
+				tagMode: "some",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidSort",
+			query: Query{
+				offset: 0,
+				limit:  int32(n),
+				sort:   "owner;DROP TABLE tasks",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidDueBefore",
+			query: Query{
+				offset:    0,
+				limit:     int32(n),
+				dueBefore: "tomorrow",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidOverdue",
+			query: Query{
+				offset:  0,
+				limit:   int32(n),
+				overdue: "maybe",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidOffset",
+			query: Query{
+				offset: -1,
+				limit:  int32(n),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidLimit",
+			query: Query{
+				offset: 0,
+				limit:  0,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListTasksSorted(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/tasks"
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			// Add query parameters to request URL
+			q := request.URL.Query()
+			q.Add("offset", fmt.Sprintf("%d", tc.query.offset))
+			q.Add("limit", fmt.Sprintf("%d", tc.query.limit))
+			if tc.query.dueBefore != "" {
+				q.Add("due_before", tc.query.dueBefore)
+			}
+			if tc.query.dueAfter != "" {
+				q.Add("due_after", tc.query.dueAfter)
+			}
+			if tc.query.overdue != "" {
+				q.Add("overdue", tc.query.overdue)
+			}
+			if tc.query.sort != "" {
+				q.Add("sort", tc.query.sort)
+			}
+			for _, tag := range tc.query.tags {
+				q.Add("tag", tag)
+			}
+			if tc.query.tagMode != "" {
+				q.Add("tag_mode", tc.query.tagMode)
+			}
+			request.URL.RawQuery = q.Encode()
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateTaskAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	task1 := randomTask(user.Username)
+	task2 := randomTask(user.Username)
+	task2.ID = task1.ID
+	task2.Done = true
+	parent := randomTask(user.Username)
+	parent.ID = task1.ID + 1
+	foreignParent := randomTask("pepito")
+	foreignParent.ID = task1.ID + 2
+	nextTask := randomTask(user.Username)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+				"done":        task2.Done,
+				"due_at":      task2.DueAt,
+				"priority":    task2.Priority,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: task2.Description,
+						Done:        task2.Done,
+						DueAt:       task2.DueAt,
+						Priority:    task2.Priority,
+					},
+				}
+
+				store.EXPECT().
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.UpdateTaskTxResult{Task: task2}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Eq(task2.ID)).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTask(t, recorder.Body, task2)
+			},
+		},
+		{
+			name: "OKCascade",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+				"done":        task2.Done,
+				"parent_id":   parent.ID,
+				"cascade":     true,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: task2.Description,
+						Done:        task2.Done,
+						DueAt:       task1.DueAt,
+						Priority:    task1.Priority,
+						ParentID:    &parent.ID,
+					},
+					CascadeDone: true,
+				}
+
+				store.EXPECT().
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.UpdateTaskTxResult{Task: task2}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Eq(task2.ID)).
+					Times(1).
+					Return(db.GetTaskProgressRow{Total: 4, Done: 4}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTask(t, recorder.Body, task2)
+			},
+		},
+		{
+			name: "OKRecurring",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+				"done":        true,
+				"recurrence":  "freq=weekly;byday=mo",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: task2.Description,
+						Done:        true,
+						DueAt:       task1.DueAt,
+						Priority:    task1.Priority,
+						Recurrence:  "FREQ=WEEKLY;BYDAY=MO",
+					},
+				}
+
+				store.EXPECT().
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.UpdateTaskTxResult{Task: task2, NextTask: &nextTask}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Eq(task2.ID)).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp taskResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, task2, rsp.Task)
+				require.Equal(t, &nextTask, rsp.NextOccurrence)
+			},
+		},
+		{
+			name: "KeepRecurrence",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				recurring := task1
+				recurring.Recurrence = "FREQ=DAILY"
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(recurring, nil)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: task2.Description,
+						DueAt:       task1.DueAt,
+						Priority:    task1.Priority,
+						Recurrence:  "FREQ=DAILY",
+					},
+				}
+
+				store.EXPECT().
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(task, nil)
+					Return(db.UpdateTaskTxResult{Task: task2}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-
-			store := mockdb.NewMockStore(ctrl)
-			tc.buildStubs(store)
-
-			// start test server and send request
-			server := newTestServer(t, store)
-			recorder := httptest.NewRecorder()
-
-			url := fmt.Sprintf("/tasks/%d", tc.taskID)
-			request, err := http.NewRequest(http.MethodGet, url, nil)
-			require.NoError(t, err)
-
-			tc.setupAuth(t, request, server.tokenMaker)
-			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
-		})
-	}
-}
-
-func TestListTasksAPI(t *testing.T) {
-	user, _ := randomUser(t)
-
-	n := 5
-	tasks := make([]db.Task, n)
-	for i := 0; i < n; i++ {
-		tasks[i] = randomTask(user.Username)
-	}
-
-	type Query struct {
-		offset int32
-		limit  int32
-	}
-
-	testCases := []struct {
-		name          string
-		query         Query
-		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
-		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
-	}{
 		{
-			name: "OK",
-			query: Query{
-				offset: 0,
-				limit:  int32(n),
+			name: "KeepMissingFields",
+			body: map[string]any{
+				"id":    task2.ID,
+				"title": task2.Title,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
-				arg := db.ListTasksParams{
-					Owner:  user.Username,
-					Offset: 0,
-					Limit:  5,
+				projectID := util.RandomID()
+				stored := task1
+				stored.Done = true
+				stored.ProjectID = &projectID
+				stored.ParentID = &parent.ID
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(stored, nil)
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(parent.ID)).Times(0)
+				store.EXPECT().GetProject(gomock.Any(), gomock.Any()).Times(0)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: stored.Description,
+						Done:        true,
+						DueAt:       stored.DueAt,
+						Priority:    stored.Priority,
+						ProjectID:   &projectID,
+						ParentID:    &parent.ID,
+					},
 				}
 
 				store.EXPECT().
-					ListTasks(gomock.Any(), gomock.Eq(arg)).
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return(tasks, nil)
+					Return(db.UpdateTaskTxResult{Task: stored}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				requireBodyMatchTasks(t, recorder.Body, tasks)
 			},
 		},
 		{
-			name: "InternalServerError",
-			query: Query{
-				offset: 0,
-				limit:  int32(n),
+			name: "ClearNullFields",
+			body: map[string]any{
+				"id":         task2.ID,
+				"title":      task2.Title,
+				"due_at":     nil,
+				"project_id": nil,
+				"parent_id":  nil,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				projectID := util.RandomID()
+				stored := task1
+				stored.ProjectID = &projectID
+				stored.ParentID = &parent.ID
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(stored, nil)
+
+				arg := db.UpdateTaskTxParams{
+					UpdateTaskParams: db.UpdateTaskParams{
+						ID:          task2.ID,
+						Title:       task2.Title,
+						Description: stored.Description,
+						Priority:    stored.Priority,
+					},
+				}
+
 				store.EXPECT().
-					ListTasks(gomock.Any(), gomock.Any()).
+					UpdateTaskTx(gomock.Any(), gomock.Eq(arg)).
 					Times(1).
-					Return([]db.Task{}, sql.ErrConnDone)
+					Return(db.UpdateTaskTxResult{Task: task2}, nil)
+				store.EXPECT().
+					GetTaskProgress(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetTaskProgressRow{}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
 		{
-			name: "InvalidOffset",
-			query: Query{
-				offset: -1,
-				limit:  int32(n),
+			name: "InvalidRecurrence",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+				"recurrence":  "FREQ=HOURLY",
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					ListTasks(gomock.Any(), gomock.Any()).
-					Times(0)
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
+				store.EXPECT().UpdateTaskTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
 		{
-			name: "InvalidLimit",
-			query: Query{
-				offset: 0,
-				limit:  0,
+			name: "TaskCycle",
+			body: map[string]any{
+				"id":          task2.ID,
+				"title":       task2.Title,
+				"description": task2.Description,
+				"parent_id":   parent.ID,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(parent.ID)).Times(1).Return(parent, nil)
 				store.EXPECT().
-					ListTasks(gomock.Any(), gomock.Any()).
-					Times(0)
+					UpdateTaskTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateTaskTxResult{}, db.ErrTaskCycle)
+				store.EXPECT().GetTaskProgress(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTaskCycle)
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-
-			store := mockdb.NewMockStore(ctrl)
-			tc.buildStubs(store)
-
-			// start test server and send request
-			server := newTestServer(t, store)
-			recorder := httptest.NewRecorder()
-
-			url := "/tasks"
-			request, err := http.NewRequest(http.MethodGet, url, nil)
-			require.NoError(t, err)
-
-			// Add query parameters to request URL
-			q := request.URL.Query()
-			q.Add("offset", fmt.Sprintf("%d", tc.query.offset))
-			q.Add("limit", fmt.Sprintf("%d", tc.query.limit))
-			request.URL.RawQuery = q.Encode()
-
-			tc.setupAuth(t, request, server.tokenMaker)
-			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
-		})
-	}
-}
-
-func TestUpdateTaskAPI(t *testing.T) {
-	user, _ := randomUser(t)
-	task1 := randomTask(user.Username)
-	task2 := randomTask(user.Username)
-	task2.ID = task1.ID
-	task2.Done = true
-
-	testCases := []struct {
-		name          string
-		body          map[string]any
-		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
-		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
-	}{
 		{
-			name: "OK",
+			name: "ForeignParent",
 			body: map[string]any{
 				"id":          task2.ID,
 				"title":       task2.Title,
 				"description": task2.Description,
-				"done":        task2.Done,
+				"parent_id":   foreignParent.ID,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
-
-				arg := db.UpdateTaskParams{
-					ID:          task2.ID,
-					Title:       task2.Title,
-					Description: task2.Description,
-					Done:        task2.Done,
-				}
-
-				store.EXPECT().
-					UpdateTask(gomock.Any(), gomock.Eq(arg)).
-					Times(1).
-					Return(task2, nil)
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(foreignParent.ID)).Times(1).Return(foreignParent, nil)
+				store.EXPECT().UpdateTaskTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusOK, recorder.Code)
-				requireBodyMatchTask(t, recorder.Body, task2)
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
 		{
@@ -448,7 +1285,7 @@ func TestUpdateTaskAPI(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Any()).Times(1).Return(db.Task{}, sql.ErrNoRows)
 				store.EXPECT().
-					UpdateTask(gomock.Any(), gomock.Any()).
+					UpdateTaskTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -469,9 +1306,9 @@ func TestUpdateTaskAPI(t *testing.T) {
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
 				store.EXPECT().
-					UpdateTask(gomock.Any(), gomock.Any()).
+					UpdateTaskTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.Task{}, sql.ErrConnDone)
+					Return(db.UpdateTaskTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -490,7 +1327,7 @@ func TestUpdateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Any()).Times(0)
-				store.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateTaskTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -509,7 +1346,7 @@ func TestUpdateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Any()).Times(0)
-				store.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateTaskTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -528,7 +1365,7 @@ func TestUpdateTaskAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task1.ID)).Times(1).Return(task1, nil)
-				store.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UpdateTaskTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -563,6 +1400,132 @@ func TestUpdateTaskAPI(t *testing.T) {
 	}
 }
 
+func TestListSubtasksAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	task := randomTask(user.Username)
+
+	n := 3
+	subtasks := make([]db.Task, n)
+	for i := 0; i < n; i++ {
+		subtasks[i] = randomTask(user.Username)
+		subtasks[i].ParentID = &task.ID
+	}
+
+	testCases := []struct {
+		name          string
+		taskID        int64
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			taskID:   task.ID,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task.ID)).Times(1).Return(task, nil)
+				store.EXPECT().
+					ListSubtasks(gomock.Any(), gomock.Eq(task.ID)).
+					Times(1).
+					Return(subtasks, nil)
+				store.EXPECT().
+					ListTaskProgress(gomock.Any(), gomock.Eq(taskIDs(subtasks))).
+					Times(1).
+					Return([]db.ListTaskProgressRow{{ParentID: subtasks[0].ID, Total: 4, Done: 1}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []taskResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp, n)
+				for i, task := range rsp {
+					require.Equal(t, subtasks[i], task.Task)
+				}
+
+				// only the subtasks that have subtasks of their own have progress
+				require.NotNil(t, rsp[0].Progress)
+				require.Equal(t, 0.25, *rsp[0].Progress)
+				require.Nil(t, rsp[1].Progress)
+			},
+		},
+		{
+			name:     "NotFound",
+			taskID:   task.ID,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Any()).Times(1).Return(db.Task{}, sql.ErrNoRows)
+				store.EXPECT().ListSubtasks(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "UnauthorizedUser",
+			taskID:   task.ID,
+			username: "pepito",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task.ID)).Times(1).Return(task, nil)
+				store.EXPECT().ListSubtasks(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalServerError",
+			taskID:   task.ID,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Eq(task.ID)).Times(1).Return(task, nil)
+				store.EXPECT().
+					ListSubtasks(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Task{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidID",
+			taskID:   0,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTask(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListSubtasks(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/tasks/%d/subtasks", tc.taskID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
 func TestDeleteTaskAPI(t *testing.T) {
 	user, _ := randomUser(t)
 	task := randomTask(user.Username)
@@ -698,12 +1661,25 @@ func TestDeleteTaskAPI(t *testing.T) {
 }
 
 func randomTask(owner string) db.Task {
+	dueAt := time.Now().UTC().Truncate(time.Second).Add(time.Duration(util.RandomInt(1, 72)) * time.Hour)
 	return db.Task{
 		ID:          util.RandomID(),
 		Owner:       owner,
 		Title:       util.RandomTitle(),
 		Description: util.RandomPassword(100),
+		DueAt:       &dueAt,
+		Priority:    randomPriority(),
+	}
+}
+
+func randomPriority() db.TaskPriority {
+	priorities := []db.TaskPriority{
+		db.TaskPriorityLow,
+		db.TaskPriorityMedium,
+		db.TaskPriorityHigh,
+		db.TaskPriorityUrgent,
 	}
+	return priorities[util.RandomInt(0, len(priorities))]
 }
 
 func requireBodyMatchTask(t *testing.T, body *bytes.Buffer, task db.Task) {
@@ -716,6 +1692,17 @@ func requireBodyMatchTask(t *testing.T, body *bytes.Buffer, task db.Task) {
 	require.Equal(t, task, gotTask)
 }
 
+func requireBodyMatchTaskResponse(t *testing.T, body *bytes.Buffer, task db.Task, progress *float64) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotTask taskResponse
+	err = json.Unmarshal(data, &gotTask)
+	require.NoError(t, err)
+	require.Equal(t, task, gotTask.Task)
+	require.Equal(t, progress, gotTask.Progress)
+}
+
 func requireBodyMatchTasks(t *testing.T, body *bytes.Buffer, tasks []db.Task) {
 	data, err := io.ReadAll(body)
 	require.NoError(t, err)
@@ -725,3 +1712,11 @@ func requireBodyMatchTasks(t *testing.T, body *bytes.Buffer, tasks []db.Task) {
 	require.NoError(t, err)
 	require.Equal(t, tasks, gotTasks)
 }
+
+func taskIDs(tasks []db.Task) []int64 {
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+const (
+	codeSessionBlocked = "session_blocked"
+	codeSessionInvalid = "session_invalid"
+)
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" requirements:"required"`
+}
+
+type renewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+func (server *Server) renewAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req renewAccessTokenRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken, token.TokenTypeRefreshToken)
+	if err != nil {
+		writeError(w, tokenError(err))
+		return
+	}
+
+	session, err := server.store.GetSession(r.Context(), refreshPayload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("session"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	if session.IsBlocked {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeSessionBlocked, "session is blocked"))
+		return
+	}
+
+	if session.Username != refreshPayload.Username {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeSessionInvalid, "incorrect session user"))
+		return
+	}
+
+	if session.RefreshToken != req.RefreshToken {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeSessionInvalid, "mismatched session token"))
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeTokenExpired, "session has expired"))
+		return
+	}
+
+	// the role and the lock are read again so a demoted or locked user can't
+	// keep the old access for the whole lifetime of the refresh token
+	user, err := server.store.GetUser(r.Context(), session.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, newAPIError(http.StatusUnauthorized, codeSessionInvalid, "the session user no longer exists"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	if user.LockedAt != nil {
+		writeError(w, newAPIError(http.StatusForbidden, codeAccountLocked, "the account is locked"))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, server.config.AccessTokenDuration, token.TokenTypeAccessToken)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := renewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiresAt,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+// clientIP returns the address of the client without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
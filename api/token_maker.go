@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+// newTokenMaker creates the token maker selected in the config, PASETO is used
+// when none is selected. Local PASETO tokens are signed with the symmetric key
+// and verified with the retired keys too, public PASETO tokens are signed with
+// the Ed25519 private key file, JWTs are signed with the private key file when
+// there is one and with the symmetric key otherwise. The tokens carry the
+// issuer and audience of the config and the ones verified must match them
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	maker, err := newConfiguredMaker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	maker.(token.ClaimsSetter).SetClaims(token.Claims{
+		Issuer:   config.TokenIssuer,
+		Audience: config.TokenAudience,
+	})
+	return maker, nil
+}
+
+func newConfiguredMaker(config util.Config) (token.Maker, error) {
+	switch config.TokenType {
+	case "paseto", "":
+		retired, err := token.ParseSymmetricKeys(config.TokenRetiredKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		active := token.SymmetricKey{
+			ID:  config.TokenKeyID,
+			Key: config.TokenSymmetricKey,
+		}
+		return token.NewPasetoKeyRingMaker(active, retired...)
+	case "paseto-public":
+		privateKeyPEM, err := os.ReadFile(config.TokenPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewPublicPasetoMaker(privateKeyPEM)
+	case "jwt":
+		if config.TokenPrivateKeyFile == "" {
+			return token.NewJWTMaker(config.TokenSymmetricKey)
+		}
+
+		privateKeyPEM, err := os.ReadFile(config.TokenPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewAsymmetricJWTMaker(privateKeyPEM)
+	default:
+		return nil, fmt.Errorf("unsupported token type %s", config.TokenType)
+	}
+}
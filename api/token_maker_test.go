@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPrivateKeyFile(t *testing.T) string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	privateKeyFile := filepath.Join(t.TempDir(), "token.pem")
+	err = os.WriteFile(privateKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	require.NoError(t, err)
+
+	return privateKeyFile
+}
+
+// requireJWTAlg checks the signing algorithm in the header of the tokens of a maker
+func requireJWTAlg(t *testing.T, maker token.Maker, alg string) {
+	tokenString, _, err := maker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(tokenString, ".")[0])
+	require.NoError(t, err)
+
+	var fields struct {
+		Alg string `json:"alg"`
+	}
+	err = json.Unmarshal(header, &fields)
+	require.NoError(t, err)
+	require.Equal(t, alg, fields.Alg)
+}
+
+func TestNewTokenMaker(t *testing.T) {
+	privateKeyFile := randomPrivateKeyFile(t)
+	symmetricKey := util.RandomPassword(32)
+
+	maker, err := newTokenMaker(util.Config{TokenSymmetricKey: symmetricKey})
+	require.NoError(t, err)
+	require.IsType(t, &token.PasetoMaker{}, maker)
+
+	maker, err = newTokenMaker(util.Config{TokenType: "jwt", TokenSymmetricKey: symmetricKey})
+	require.NoError(t, err)
+	require.IsType(t, &token.JWTMaker{}, maker)
+	requireJWTAlg(t, maker, "HS256")
+
+	maker, err = newTokenMaker(util.Config{TokenType: "jwt", TokenPrivateKeyFile: privateKeyFile})
+	require.NoError(t, err)
+	requireJWTAlg(t, maker, "EdDSA")
+
+	maker, err = newTokenMaker(util.Config{TokenType: "paseto-public", TokenPrivateKeyFile: privateKeyFile})
+	require.NoError(t, err)
+	require.IsType(t, &token.PublicPasetoMaker{}, maker)
+
+	_, err = newTokenMaker(util.Config{TokenType: "paseto-public"})
+	require.Error(t, err)
+
+	_, err = newTokenMaker(util.Config{TokenType: "jwt", TokenPrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+
+	_, err = newTokenMaker(util.Config{TokenType: "macaroon", TokenSymmetricKey: symmetricKey})
+	require.Error(t, err)
+}
+
+func TestNewTokenMakerKeyRing(t *testing.T) {
+	oldKey := util.RandomPassword(32)
+
+	oldMaker, err := newTokenMaker(util.Config{TokenKeyID: "2024-01", TokenSymmetricKey: oldKey})
+	require.NoError(t, err)
+
+	oldToken, _, err := oldMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	config := util.Config{
+		TokenKeyID:        "2024-02",
+		TokenSymmetricKey: util.RandomPassword(32),
+		TokenRetiredKeys:  "2024-01:" + oldKey + ":" + time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+
+	maker, err := newTokenMaker(config)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(oldToken, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	config.TokenRetiredKeys = "2024-01:" + oldKey
+	_, err = newTokenMaker(config)
+	require.Error(t, err)
+}
+
+func TestNewTokenMakerClaims(t *testing.T) {
+	privateKeyFile := randomPrivateKeyFile(t)
+
+	for _, tokenType := range []string{"paseto", "paseto-public", "jwt"} {
+		t.Run(tokenType, func(t *testing.T) {
+			config := util.Config{
+				TokenType:         tokenType,
+				TokenSymmetricKey: util.RandomPassword(32),
+				TokenIssuer:       "devoratasks",
+				TokenAudience:     "devoratasks-api",
+			}
+			if tokenType == "paseto-public" {
+				config.TokenPrivateKeyFile = privateKeyFile
+			}
+
+			maker, err := newTokenMaker(config)
+			require.NoError(t, err)
+
+			tokenString, payload, err := maker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			require.Equal(t, config.TokenIssuer, payload.Issuer)
+			require.Equal(t, config.TokenAudience, payload.Audience)
+
+			gotPayload, err := maker.VerifyToken(tokenString, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			require.Equal(t, config.TokenIssuer, gotPayload.Issuer)
+			require.Equal(t, config.TokenAudience, gotPayload.Audience)
+
+			// the same keys with another audience must reject the token
+			maker.(token.ClaimsSetter).SetClaims(token.Claims{Issuer: config.TokenIssuer, Audience: "another-api"})
+			gotPayload, err = maker.VerifyToken(tokenString, token.TokenTypeAccessToken)
+			require.EqualError(t, err, token.ErrInvalidToken.Error())
+			require.Nil(t, gotPayload)
+		})
+	}
+}
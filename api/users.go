@@ -3,16 +3,21 @@ package api
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
-	ctx "github.com/gorilla/context"
-
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
 	"github.com/DMV-Nicolas/DevoraTasks/token"
 	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
 )
 
+const codeAccountLocked = "account_locked"
+
 type createUserRequest struct {
 	Username string `json:"username" requirements:"required"`
 	Email    string `json:"email" requirements:"required;email"`
@@ -23,6 +28,10 @@ type userResponse struct {
 	Username          string    `json:"username"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	Timezone          string    `json:"timezone"`
+	Locale            string    `json:"locale"`
+	Role              string    `json:"role"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -30,7 +39,12 @@ type userResponse struct {
 func newUserResponse(user db.User) userResponse {
 	return userResponse{
 		Username:          user.Username,
+		FullName:          user.FullName,
 		Email:             user.Email,
+		IsEmailVerified:   user.IsEmailVerified,
+		Timezone:          user.Timezone,
+		Locale:            user.Locale,
+		Role:              user.Role,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
@@ -40,53 +54,64 @@ func (server *Server) createUser(w http.ResponseWriter, r *http.Request) {
 	var req createUserRequest
 	err := util.ShouldBindJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
 		return
 	}
 
 	hashedPassword, err := util.HashPassword(req.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
-	arg := db.CreateUserParams{
-		Username:       req.Username,
-		Email:          req.Email,
-		HashedPassword: hashedPassword,
+	code, err := util.SecureToken(verifyCodeSize)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
 	}
 
-	user, err := server.store.CreateUser(context.Background(), arg)
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:       req.Username,
+			Email:          req.Email,
+			HashedPassword: hashedPassword,
+		},
+		VerifyCodeHash:  util.HashToken(code),
+		VerifyExpiresAt: time.Now().Add(server.config.VerifyEmailDuration),
+		AfterCreate: func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyEmail(r.Context(), user, verifyEmail, code)
+		},
+	}
+
+	result, err := server.store.CreateUserTx(r.Context(), arg)
 	if err != nil {
 		if db.ErrorCode(err) == db.UniqueViolation {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write(errorResponse(err))
+			writeError(w, newAPIError(http.StatusForbidden, codeAlreadyExists, "username or email is already in use"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
-	res := newUserResponse(user)
+	res := newUserResponse(result.User)
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write(jsonResponse(res))
 }
 
 func (server *Server) getUser(w http.ResponseWriter, r *http.Request) {
-	payload := ctx.Get(r, authorizationPayloadKey).(*token.Payload)
-	user, err := server.store.GetUser(context.Background(), payload.Username)
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	user, err := server.store.GetUser(r.Context(), payload.Username)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write(errorResponse(err))
+			writeError(w, notFoundError("user"))
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
@@ -96,56 +121,483 @@ func (server *Server) getUser(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResponse(res))
 }
 
+type updateUserRequest struct {
+	Email    *string `json:"email" requirements:"email"`
+	FullName *string `json:"full_name" requirements:"max=100"`
+	Timezone *string `json:"timezone" requirements:"required"`
+	Locale   *string `json:"locale" requirements:"required"`
+}
+
+// updateUser changes the profile of the authenticated user, only the fields
+// sent by the client are updated. A new email must be verified again
+func (server *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var req updateUserRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	user, err := server.store.GetUser(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	arg := db.UpdateUserTxParams{
+		UpdateUserProfileParams: db.UpdateUserProfileParams{
+			Username:        user.Username,
+			Email:           user.Email,
+			FullName:        user.FullName,
+			Timezone:        user.Timezone,
+			Locale:          user.Locale,
+			IsEmailVerified: user.IsEmailVerified,
+		},
+	}
+
+	if req.FullName != nil {
+		arg.FullName = strings.TrimSpace(*req.FullName)
+	}
+
+	if req.Timezone != nil {
+		arg.Timezone, err = normalizeTimezone(*req.Timezone)
+		if err != nil {
+			writeError(w, fieldError("timezone", err))
+			return
+		}
+	}
+
+	if req.Locale != nil {
+		arg.Locale, err = normalizeLocale(*req.Locale)
+		if err != nil {
+			writeError(w, fieldError("locale", err))
+			return
+		}
+	}
+
+	if req.Email != nil && *req.Email != user.Email {
+		code, err := util.SecureToken(verifyCodeSize)
+		if err != nil {
+			writeError(w, internalError(r, err))
+			return
+		}
+
+		arg.Email = *req.Email
+		arg.IsEmailVerified = false
+		arg.VerifyCodeHash = util.HashToken(code)
+		arg.VerifyExpiresAt = time.Now().Add(server.config.VerifyEmailDuration)
+		arg.AfterUpdate = func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyEmail(r.Context(), user, verifyEmail, code)
+		}
+	}
+
+	result, err := server.store.UpdateUserTx(r.Context(), arg)
+	if err != nil {
+		if db.ErrorCode(err) == db.UniqueViolation {
+			writeError(w, newAPIError(http.StatusForbidden, codeAlreadyExists, "email is already in use"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := newUserResponse(result.User)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+// normalizeTimezone validates an IANA timezone name and returns its canonical form
+func normalizeTimezone(name string) (string, error) {
+	if name == "Local" {
+		return "", fmt.Errorf("unknown time zone %s", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return "", err
+	}
+
+	return loc.String(), nil
+}
+
+// normalizeLocale validates a BCP 47 language tag and returns its canonical form
+func normalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", err
+	}
+
+	return tag.String(), nil
+}
+
 type loginUserRequest struct {
 	Username string `json:"username" requirements:"required"`
 	Password string `json:"password" requirements:"required;min=8"`
 }
 
 type loginUserResponse struct {
-	AccessToken string       `json:"access_token"`
-	User        userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
 }
 
 func (server *Server) loginUser(w http.ResponseWriter, r *http.Request) {
 	var req loginUserRequest
 	err := util.ShouldBindJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(errorResponse(err))
+		writeError(w, validationError(err))
+		return
+	}
+
+	attempt, ok := server.reserveLogin(w, req.Username, clientIP(r))
+	if !ok {
 		return
 	}
+	defer attempt.release()
 
-	user, err := server.store.GetUser(context.Background(), req.Username)
+	user, err := server.store.GetUser(r.Context(), req.Username)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write(errorResponse(err))
+			// compare against a dummy hash so unknown usernames take as long
+			// as wrong passwords and can't be told apart
+			hash, _ := dummyPasswordHash()
+			util.CheckPassword(req.Password, hash)
+			server.failLogin(w, attempt)
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
+	// a locked account fails like a wrong password and is throttled the same,
+	// so the response tells neither that the username exists and is locked nor
+	// whether the password is right. The password is still compared so both
+	// take as long
 	err = util.CheckPassword(req.Password, user.HashedPassword)
+	if user.LockedAt != nil || err != nil {
+		server.failLogin(w, attempt)
+		return
+	}
+
+	userTotp, err := server.store.GetUserTotp(r.Context(), user.Username)
+	if err != nil && err != sql.ErrNoRows {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	// the failed logins of the username are kept until the second step passes
+	if err == nil && userTotp.ConfirmedAt != nil {
+		server.createMFAChallenge(w, r, user)
+		return
+	}
+
+	server.loginUsers.Reset(user.Username)
+	server.startSession(w, r, user)
+}
+
+// startSession creates a session for the user and writes its access and refresh tokens
+func (server *Server) startSession(w http.ResponseWriter, r *http.Request, user db.User) {
+	if user.LockedAt != nil {
+		writeError(w, newAPIError(http.StatusForbidden, codeAccountLocked, "the account is locked"))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, server.config.AccessTokenDuration, token.TokenTypeAccessToken)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, server.config.RefreshTokenDuration, token.TokenTypeRefreshToken)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
-	token, err := server.tokenMaker.CreateToken(user.Username, server.config.AccessTokenDuration)
+	session, err := server.store.CreateSession(r.Context(), db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    r.UserAgent(),
+		ClientIp:     clientIP(r),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiresAt,
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errorResponse(err))
+		writeError(w, internalError(r, err))
 		return
 	}
 
 	res := loginUserResponse{
-		AccessToken: token,
-		User:        newUserResponse(user),
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiresAt,
+		User:                  newUserResponse(user),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+// loginAttempt is a login reserved in the throttles of the username and the
+// client ip before the credentials are checked, so concurrent logins can't go
+// over the lockout. It ends with fail or release
+type loginAttempt struct {
+	server   *Server
+	username string
+	ip       string
+	ended    bool
+}
+
+// reserveLogin reserves a login attempt, it writes an error and returns false
+// when the username or the client ip are locked out because of too many failed
+// logins. The attempt must be released once it's handled
+func (server *Server) reserveLogin(w http.ResponseWriter, username string, ip string) (*loginAttempt, bool) {
+	wait, ok := server.loginIPs.Reserve(ip)
+	if ok {
+		wait, ok = server.loginUsers.Reserve(username)
+		if !ok {
+			server.loginIPs.Release(ip)
+		}
+	}
+	if !ok {
+		writeTooManyRequests(w, wait, "too many failed login attempts, try again later")
+		return nil, false
+	}
+
+	attempt := &loginAttempt{
+		server:   server,
+		username: username,
+		ip:       ip,
+	}
+	return attempt, true
+}
+
+// fail records the attempt as a failed login of the username and the client ip
+func (attempt *loginAttempt) fail() {
+	if attempt.ended {
+		return
+	}
+	attempt.ended = true
+
+	attempt.server.loginUsers.Fail(attempt.username)
+	attempt.server.loginIPs.Fail(attempt.ip)
+}
+
+// release ends the attempt without counting it as failed, it does nothing
+// after fail
+func (attempt *loginAttempt) release() {
+	if attempt.ended {
+		return
+	}
+	attempt.ended = true
+
+	attempt.server.loginUsers.Release(attempt.username)
+	attempt.server.loginIPs.Release(attempt.ip)
+}
+
+// failLogin records a failed login and writes the same error whether the
+// username exists or not
+func (server *Server) failLogin(w http.ResponseWriter, attempt *loginAttempt) {
+	attempt.fail()
+	writeError(w, newAPIError(http.StatusUnauthorized, codeInvalidCredentials, "invalid username or password"))
+}
+
+var dummyHash struct {
+	once sync.Once
+	hash string
+	err  error
+}
+
+// dummyPasswordHash returns the hash of a random password with the same cost
+// as the real hashes. It's computed once, NewServer computes it so no login
+// pays for it
+func dummyPasswordHash() (string, error) {
+	dummyHash.once.Do(func() {
+		dummyHash.hash, dummyHash.err = util.HashPassword(util.RandomPassword(16))
+	})
+	return dummyHash.hash, dummyHash.err
+}
+
+type logoutUserRequest struct {
+	RefreshToken string `json:"refresh_token" requirements:"required"`
+}
+
+// logoutUser blocks the session of the refresh token and revokes both the
+// refresh token and the access token used in the request
+func (server *Server) logoutUser(w http.ResponseWriter, r *http.Request) {
+	var req logoutUserRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken, token.TokenTypeRefreshToken)
+	if err != nil {
+		writeError(w, tokenError(err))
+		return
+	}
+
+	if refreshPayload.Username != payload.Username {
+		writeError(w, accessDeniedError("session"))
+		return
+	}
+
+	session, err := server.store.BlockSession(r.Context(), refreshPayload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("session"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.Revoke(r.Context(), session.ID, session.ExpiresAt)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.Revoke(r.Context(), payload.ID, payload.ExpiresAt)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllUserSessions blocks every active session of the authenticated user
+// and revokes their refresh tokens and every access token issued before the
+// request, on this device or any other
+func (server *Server) logoutAllUserSessions(w http.ResponseWriter, r *http.Request) {
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	err := server.revokeUserSessions(r.Context(), payload.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), payload.Username, time.Now())
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeUserSessions blocks every active session of the user and revokes their refresh tokens
+func (server *Server) revokeUserSessions(ctx context.Context, username string) error {
+	sessions, err := server.store.BlockSessionsByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err = server.denylist.Revoke(ctx, session.ID, session.ExpiresAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type changeUserPasswordRequest struct {
+	CurrentPassword string `json:"current_password" requirements:"required"`
+	NewPassword     string `json:"new_password" requirements:"required;min=8"`
+}
+
+// changeUserPassword replaces the password of the authenticated user, every
+// token issued before the change stops working and the user must log in again
+func (server *Server) changeUserPassword(w http.ResponseWriter, r *http.Request) {
+	var req changeUserPasswordRequest
+	err := util.ShouldBindJSON(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return
+	}
+
+	user, err := server.store.GetUser(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = util.CheckPassword(req.CurrentPassword, user.HashedPassword)
+	if err != nil {
+		writeError(w, newAPIError(http.StatusUnauthorized, codeInvalidCredentials, "incorrect password"))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
 	}
 
+	arg := db.UpdateUserPasswordParams{
+		Username:          user.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	user, err = server.store.UpdateUserPassword(r.Context(), arg)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.denylist.RevokeIssuedBefore(r.Context(), user.Username, user.PasswordChangedAt)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	err = server.revokeUserSessions(r.Context(), user.Username)
+	if err != nil {
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := newUserResponse(user)
+
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonResponse(res))
 }
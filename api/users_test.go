@@ -2,8 +2,10 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,19 +16,20 @@ import (
 
 	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/throttle"
 	"github.com/DMV-Nicolas/DevoraTasks/token"
 	"github.com/DMV-Nicolas/DevoraTasks/util"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 )
 
-type eqCreateUserParamsMatcher struct {
+type eqCreateUserTxParamsMatcher struct {
 	arg      db.CreateUserParams
 	password string
 }
 
-func (e eqCreateUserParamsMatcher) Matches(x interface{}) bool {
-	arg, ok := x.(db.CreateUserParams)
+func (e eqCreateUserTxParamsMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.CreateUserTxParams)
 	if !ok {
 		return false
 	}
@@ -36,20 +39,52 @@ func (e eqCreateUserParamsMatcher) Matches(x interface{}) bool {
 		return false
 	}
 
+	if arg.VerifyCodeHash == "" || arg.AfterCreate == nil {
+		return false
+	}
+
 	e.arg.HashedPassword = arg.HashedPassword
-	return reflect.DeepEqual(e.arg, arg)
+	return reflect.DeepEqual(e.arg, arg.CreateUserParams)
 }
 
-func (e eqCreateUserParamsMatcher) String() string {
+func (e eqCreateUserTxParamsMatcher) String() string {
 	return fmt.Sprintf("matches arg %v and password %v", e.arg, e.password)
 }
 
+type eqUpdateUserPasswordParamsMatcher struct {
+	username string
+	password string
+}
+
+func (e eqUpdateUserPasswordParamsMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.UpdateUserPasswordParams)
+	if !ok {
+		return false
+	}
+
+	err := util.CheckPassword(e.password, arg.HashedPassword)
+	if err != nil {
+		return false
+	}
+
+	return arg.Username == e.username && time.Since(arg.PasswordChangedAt) < time.Minute
+}
+
+func (e eqUpdateUserPasswordParamsMatcher) String() string {
+	return fmt.Sprintf("matches username %v and password %v", e.username, e.password)
+}
+
+func EqUpdateUserPasswordParams(username, password string) gomock.Matcher {
+	return eqUpdateUserPasswordParamsMatcher{username, password}
+}
+
 func TestCreateUserAPI(t *testing.T) {
 	user, password := randomUser(t)
 
 	testCases := []struct {
 		name          string
 		body          map[string]any
+		mailerErr     error
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -67,16 +102,45 @@ func TestCreateUserAPI(t *testing.T) {
 				}
 
 				store.EXPECT().
-					CreateUser(gomock.Any(), eqCreateUserParamsMatcher{arg, password}).
+					CreateUserTx(gomock.Any(), eqCreateUserTxParamsMatcher{arg, password}).
 					Times(1).
-					Return(user, nil)
-
+					DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						verifyEmail := db.VerifyEmail{
+							ID:       1,
+							Username: user.Username,
+							Email:    user.Email,
+							CodeHash: arg.VerifyCodeHash,
+						}
+						return db.CreateUserTxResult{User: user, VerifyEmail: verifyEmail}, arg.AfterCreate(user, verifyEmail)
+					})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusCreated, recorder.Code)
 				requireBodyMatchUser(t, recorder.Body, user)
 			},
 		},
+		{
+			name: "MailerError",
+			body: map[string]any{
+				"username": user.Username,
+				"email":    user.Email,
+				"password": password,
+			},
+			mailerErr: errors.New("smtp server is down"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						err := arg.AfterCreate(user, db.VerifyEmail{Email: user.Email})
+						return db.CreateUserTxResult{}, err
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInternalError)
+			},
+		},
 		{
 			name: "InternalServerError",
 			body: map[string]any{
@@ -86,13 +150,14 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, sql.ErrConnDone)
+					Return(db.CreateUserTxResult{}, sql.ErrConnDone)
 
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInternalError)
 			},
 		},
 		{
@@ -104,13 +169,14 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, db.ErrUniqueViolation)
+					Return(db.CreateUserTxResult{}, db.ErrUniqueViolation)
 
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAlreadyExists)
 			},
 		},
 		{
@@ -122,7 +188,7 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -138,11 +204,12 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
 			},
 		},
 		{
@@ -154,7 +221,7 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -170,7 +237,7 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -193,6 +260,7 @@ func TestCreateUserAPI(t *testing.T) {
 
 			// start test server and send request
 			server := newTestServer(t, store)
+			server.mailer = &testMailer{err: tc.mailerErr}
 			recorder := httptest.NewRecorder()
 
 			url := "/users"
@@ -205,50 +273,255 @@ func TestCreateUserAPI(t *testing.T) {
 	}
 }
 
-func TestGetUserAPI(t *testing.T) {
+type eqUpdateUserTxParamsMatcher struct {
+	arg         db.UpdateUserProfileParams
+	verifyEmail bool
+}
+
+func (e eqUpdateUserTxParamsMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.UpdateUserTxParams)
+	if !ok {
+		return false
+	}
+
+	if e.verifyEmail != (arg.VerifyCodeHash != "" && arg.AfterUpdate != nil) {
+		return false
+	}
+
+	return reflect.DeepEqual(e.arg, arg.UpdateUserProfileParams)
+}
+
+func (e eqUpdateUserTxParamsMatcher) String() string {
+	return fmt.Sprintf("matches arg %v and verify email %v", e.arg, e.verifyEmail)
+}
+
+func TestUpdateUserAPI(t *testing.T) {
 	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+	newEmail := util.RandomEmail()
+
+	updatedUser := func(arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+		result := db.UpdateUserTxResult{User: user}
+		result.User.Email = arg.Email
+		result.User.FullName = arg.FullName
+		result.User.Timezone = arg.Timezone
+		result.User.Locale = arg.Locale
+		result.User.IsEmailVerified = arg.IsEmailVerified
+		return result, nil
+	}
 
 	testCases := []struct {
 		name          string
-		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		body          map[string]any
 		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer)
 	}{
 		{
 			name: "OK",
-			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			body: map[string]any{
+				"full_name": "  Nicolas Moreno ",
+				"timezone":  "America/Bogota",
+				"locale":    "es-co",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateUserProfileParams{
+					Username:        user.Username,
+					Email:           user.Email,
+					FullName:        "Nicolas Moreno",
+					Timezone:        "America/Bogota",
+					Locale:          "es-CO",
+					IsEmailVerified: true,
+				}
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), eqUpdateUserTxParamsMatcher{arg, false}).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						return updatedUser(arg)
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, mailer.emails)
+
+				var res userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.Equal(t, user.Email, res.Email)
+				require.Equal(t, "Nicolas Moreno", res.FullName)
+				require.Equal(t, "America/Bogota", res.Timezone)
+				require.Equal(t, "es-CO", res.Locale)
+				require.True(t, res.IsEmailVerified)
+			},
+		},
+		{
+			name: "NewEmail",
+			body: map[string]any{
+				"email": newEmail,
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateUserProfileParams{
+					Username:        user.Username,
+					Email:           newEmail,
+					FullName:        user.FullName,
+					Timezone:        user.Timezone,
+					Locale:          user.Locale,
+					IsEmailVerified: false,
+				}
+
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), eqUpdateUserTxParamsMatcher{arg, true}).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						result, _ := updatedUser(arg)
+						verifyEmail := db.VerifyEmail{ID: 1, Username: user.Username, Email: arg.Email}
+						result.VerifyEmail = &verifyEmail
+						return result, arg.AfterUpdate(result.User, verifyEmail)
+					})
 			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				requireBodyMatchUser(t, recorder.Body, user)
+				require.Len(t, mailer.emails, 1)
+				require.Equal(t, []string{newEmail}, mailer.emails[0].To)
+
+				var res userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.Equal(t, newEmail, res.Email)
+				require.False(t, res.IsEmailVerified)
 			},
 		},
 		{
-			name: "NotFound",
-			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			name: "SameEmail",
+			body: map[string]any{
+				"email": user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateUserProfileParams{
+					Username:        user.Username,
+					Email:           user.Email,
+					FullName:        user.FullName,
+					Timezone:        user.Timezone,
+					Locale:          user.Locale,
+					IsEmailVerified: true,
+				}
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), eqUpdateUserTxParamsMatcher{arg, false}).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						return updatedUser(arg)
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, mailer.emails)
+			},
+		},
+		{
+			name: "DuplicateEmail",
+			body: map[string]any{
+				"email": newEmail,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, db.ErrUniqueViolation)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAlreadyExists)
+			},
+		},
+		{
+			name: "InvalidEmail",
+			body: map[string]any{
+				"email": "invalid-email",
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "InvalidTimezone",
+			body: map[string]any{
+				"timezone": "Mars/Olympus_Mons",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
-					Return(db.User{}, sql.ErrNoRows)
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "InvalidLocale",
+			body: map[string]any{
+				"locale": "not a locale",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name: "EmptyTimezone",
+			body: map[string]any{
+				"timezone": "",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
 			},
 		},
 		{
 			name: "InternalServerError",
-			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			body: map[string]any{
+				"full_name": "Nicolas Moreno",
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -256,7 +529,7 @@ func TestGetUserAPI(t *testing.T) {
 					Times(1).
 					Return(db.User{}, sql.ErrConnDone)
 			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *testMailer) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
@@ -270,35 +543,71 @@ func TestGetUserAPI(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
 			// start test server and send request
 			server := newTestServer(t, store)
+			mailer := server.mailer.(*testMailer)
 			recorder := httptest.NewRecorder()
 
 			url := "/users"
-			request, err := http.NewRequest(http.MethodGet, url, nil)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
 			require.NoError(t, err)
 
-			tc.setupAuth(t, request, server.tokenMaker)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			server.router.ServeHTTP(recorder, request)
-			tc.checkResponse(t, recorder)
+			tc.checkResponse(t, recorder, mailer)
 		})
 	}
 }
 
-func TestLoginUserAPI(t *testing.T) {
-	user, password := randomUser(t)
+func TestUpdateUserStalledMailer(t *testing.T) {
+	user, _ := randomUser(t)
+	newEmail := util.RandomEmail()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		UpdateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+			verifyEmail := db.VerifyEmail{ID: 1, Username: user.Username, Email: arg.Email}
+			return db.UpdateUserTxResult{}, arg.AfterUpdate(user, verifyEmail)
+		})
+
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(map[string]any{"email": newEmail})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPatch, "/users", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+	requireStalledMailerTimeout(t, server, request)
+}
+
+func TestGetUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
 
 	testCases := []struct {
 		name          string
-		body          map[string]any
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name: "OK",
-			body: map[string]any{
-				"username": user.Username,
-				"password": password,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -308,13 +617,13 @@ func TestLoginUserAPI(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchUser(t, recorder.Body, user)
 			},
 		},
 		{
 			name: "NotFound",
-			body: map[string]any{
-				"username": user.Username,
-				"password": password,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -324,13 +633,13 @@ func TestLoginUserAPI(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "user_not_found")
 			},
 		},
 		{
 			name: "InternalServerError",
-			body: map[string]any{
-				"username": user.Username,
-				"password": password,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -343,49 +652,19 @@ func TestLoginUserAPI(t *testing.T) {
 			},
 		},
 		{
-			name: "IncorrectPassword",
-			body: map[string]any{
-				"username": user.Username,
-				"password": "incorrectPassword",
-			},
-			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					GetUser(gomock.Any(), gomock.Eq(user.Username)).
-					Times(1).
-					Return(user, nil)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusUnauthorized, recorder.Code)
-			},
-		},
-		{
-			name: "NoUsername",
-			body: map[string]any{
-				"username": "",
-				"password": password,
-			},
-			buildStubs: func(store *mockdb.MockStore) {
-				store.EXPECT().
-					GetUser(gomock.Any(), gomock.Any()).
-					Times(0)
-			},
-			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusBadRequest, recorder.Code)
-			},
-		},
-		{
-			name: "TooShortPassword",
-			body: map[string]any{
-				"username": user.Username,
-				"password": "1234",
+			name: "Timeout",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Any()).
-					Times(0)
+					Times(1).
+					Return(db.User{}, context.DeadlineExceeded)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTimeout)
 			},
 		},
 	}
@@ -398,24 +677,463 @@ func TestLoginUserAPI(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
 
-			// marshal data body to json
-			data, err := json.Marshal(tc.body)
-			require.NoError(t, err)
-
 			// start test server and send request
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
-			url := "/users/login"
-			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			url := "/users"
+			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
 
+			tc.setupAuth(t, request, server.tokenMaker)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
 	}
 }
 
+func TestLoginUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.False(t, arg.IsBlocked)
+						return db.Session{
+							ID:           arg.ID,
+							Username:     arg.Username,
+							RefreshToken: arg.RefreshToken,
+							UserAgent:    arg.UserAgent,
+							ClientIp:     arg.ClientIp,
+							ExpiresAt:    arg.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.NotZero(t, res.SessionID)
+				require.NotEmpty(t, res.AccessToken)
+				require.NotEmpty(t, res.RefreshToken)
+				require.True(t, res.RefreshTokenExpiresAt.After(res.AccessTokenExpiresAt))
+				require.Equal(t, user.Username, res.User.Username)
+				require.Equal(t, user.FullName, res.User.FullName)
+				require.Equal(t, user.Timezone, res.User.Timezone)
+				require.Equal(t, user.Locale, res.User.Locale)
+				require.Equal(t, util.UserRole, res.User.Role)
+			},
+		},
+		{
+			name: "LockedUser",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				lockedUser := user
+				lockedAt := time.Now()
+				lockedUser.LockedAt = &lockedAt
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(lockedUser, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+			},
+		},
+		{
+			name: "LockedUserWrongPassword",
+			body: map[string]any{
+				"username": user.Username,
+				"password": "wrongpassword",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				lockedUser := user
+				lockedAt := time.Now()
+				lockedUser.LockedAt = &lockedAt
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(lockedUser, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+			},
+		},
+		{
+			name: "CreateSessionError",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "MFARequired",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				confirmedAt := time.Now()
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, ConfirmedAt: &confirmedAt}, nil)
+				store.EXPECT().
+					CreateMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateMfaChallengeParams) (db.MfaChallenge, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.MfaChallenge{ID: 1, Username: arg.Username, TokenHash: arg.TokenHash, ExpiresAt: arg.ExpiresAt}, nil
+					})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res map[string]any
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.Equal(t, true, res["mfa_required"])
+				require.NotEmpty(t, res["mfa_token"])
+				require.NotContains(t, res, "access_token")
+			},
+		},
+		{
+			name: "UnconfirmedTotp",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username}, nil)
+				store.EXPECT().
+					CreateMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.NotEmpty(t, res.AccessToken)
+			},
+		},
+		{
+			name: "GetUserTotpError",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "UnknownUsername",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// unknown usernames look the same as wrong passwords
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			body: map[string]any{
+				"username": user.Username,
+				"password": "incorrectPassword",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+			},
+		},
+		{
+			name: "NoUsername",
+			body: map[string]any{
+				"username": "",
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TooShortPassword",
+			body: map[string]any{
+				"username": user.Username,
+				"password": "1234",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/login"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserThrottle(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	server.loginUsers = throttle.New(2, time.Minute, time.Hour)
+	server.loginIPs = throttle.New(4, time.Minute, time.Hour)
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		data, err := json.Marshal(map[string]any{
+			"username": username,
+			"password": password,
+		})
+		require.NoError(t, err)
+
+		request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+		require.NoError(t, err)
+		request.RemoteAddr = "10.0.0.1:4321"
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(4).
+		Return(user, nil)
+	store.EXPECT().
+		GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(db.UserTotp{}, sql.ErrNoRows)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.Session{}, nil)
+
+	// a successful login forgets the failures of the username
+	require.Equal(t, http.StatusUnauthorized, login(user.Username, "incorrectPassword").Code)
+	require.Equal(t, http.StatusOK, login(user.Username, password).Code)
+
+	// the username is locked out after two failures in a row
+	require.Equal(t, http.StatusUnauthorized, login(user.Username, "incorrectPassword").Code)
+	require.Equal(t, http.StatusUnauthorized, login(user.Username, "incorrectPassword").Code)
+	recorder := login(user.Username, password)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTooManyAttempts)
+	require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+
+	// the fourth failure of the ip locks it out for every username
+	otherUsername := util.RandomUsername()
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(otherUsername)).
+		Times(1).
+		Return(db.User{}, sql.ErrNoRows)
+
+	require.Equal(t, http.StatusUnauthorized, login(otherUsername, password).Code)
+	recorder = login(util.RandomUsername(), password)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTooManyAttempts)
+}
+
+func TestLoginUserLockedAccountThrottle(t *testing.T) {
+	user, password := randomUser(t)
+	lockedAt := time.Now()
+	user.LockedAt = &lockedAt
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	server.loginUsers = throttle.New(2, time.Minute, time.Hour)
+
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(2).
+		Return(user, nil)
+
+	data, err := json.Marshal(map[string]any{
+		"username": user.Username,
+		"password": password,
+	})
+	require.NoError(t, err)
+
+	// the logins of a locked account fail even with the right password and
+	// count towards the lockout of the username
+	for i := 0; i < 2; i++ {
+		request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
 func randomUser(t *testing.T) (user db.User, password string) {
 	password = util.RandomPassword(16)
 	hashedPassword, err := util.HashPassword(password)
@@ -425,6 +1143,10 @@ func randomUser(t *testing.T) (user db.User, password string) {
 		Username:       util.RandomUsername(),
 		Email:          util.RandomEmail(),
 		HashedPassword: hashedPassword,
+		FullName:       util.RandomUsername(),
+		Timezone:       "UTC",
+		Locale:         "en",
+		Role:           util.UserRole,
 	}
 
 	return
@@ -441,5 +1163,425 @@ func requireBodyMatchUser(t *testing.T, body *bytes.Buffer, user db.User) {
 	require.NoError(t, err)
 	require.Equal(t, user.Username, gotUser.Username)
 	require.Equal(t, user.Email, gotUser.Email)
+	require.Equal(t, user.FullName, gotUser.FullName)
+	require.Equal(t, user.Timezone, gotUser.Timezone)
+	require.Equal(t, user.Locale, gotUser.Locale)
 	require.Empty(t, gotUser.HashedPassword)
 }
+
+func TestLogoutUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildBody     func(refreshToken string) map[string]any
+		buildStubs    func(store *mockdb.MockStore, session db.Session)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{"refresh_token": refreshToken}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				session.IsBlocked = true
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.True(t, revoked)
+			},
+		},
+		{
+			name:     "SessionNotFound",
+			username: user.Username,
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{"refresh_token": refreshToken}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder.Body, "session_not_found")
+				require.False(t, revoked)
+			},
+		},
+		{
+			name:     "ForeignSession",
+			username: "pepito",
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{"refresh_token": refreshToken}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeAccessDenied)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name:     "InvalidRefreshToken",
+			username: user.Username,
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{"refresh_token": "invalid-token"}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeTokenInvalid)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name:     "NoRefreshToken",
+			username: user.Username,
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name:     "InternalServerError",
+			username: user.Username,
+			buildBody: func(refreshToken string) map[string]any {
+				return map[string]any{"refresh_token": refreshToken}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(user.Username, util.UserRole, time.Hour, token.TokenTypeRefreshToken)
+			require.NoError(t, err)
+
+			session := randomSession(refreshPayload, refreshToken)
+			tc.buildStubs(store, session)
+
+			accessToken, accessPayload, err := server.tokenMaker.CreateToken(tc.username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.buildBody(refreshToken))
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+
+			url := "/users/logout"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			server.router.ServeHTTP(recorder, request)
+
+			accessRevoked, err := server.denylist.IsRevoked(context.Background(), accessPayload)
+			require.NoError(t, err)
+			refreshRevoked, err := server.denylist.IsRevoked(context.Background(), refreshPayload)
+			require.NoError(t, err)
+			require.Equal(t, accessRevoked, refreshRevoked)
+
+			tc.checkResponse(t, recorder, accessRevoked)
+		})
+	}
+}
+
+func TestLogoutAllUserSessionsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 3
+	payloads := make([]*token.Payload, n)
+	sessions := make([]db.Session, n)
+	for i := 0; i < n; i++ {
+		payload, err := token.NewPayload(user.Username, util.UserRole, time.Hour, token.TokenTypeAccessToken)
+		require.NoError(t, err)
+
+		payloads[i] = payload
+		sessions[i] = randomSession(payload, util.RandomPassword(32))
+		sessions[i].IsBlocked = true
+	}
+
+	otherAccessPayload, err := token.NewPayload(user.Username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(sessions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.True(t, revoked)
+			},
+		},
+		{
+			name: "InternalServerError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+
+			url := "/users/logout_all"
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			server.router.ServeHTTP(recorder, request)
+
+			revoked, err := server.denylist.IsRevoked(context.Background(), accessPayload)
+			require.NoError(t, err)
+
+			// the access tokens of the other devices aren't in the request
+			otherRevoked, err := server.denylist.IsRevoked(context.Background(), otherAccessPayload)
+			require.NoError(t, err)
+			require.Equal(t, revoked, otherRevoked)
+
+			for _, payload := range payloads {
+				sessionRevoked, err := server.denylist.IsRevoked(context.Background(), payload)
+				require.NoError(t, err)
+				require.Equal(t, revoked, sessionRevoked)
+			}
+
+			tc.checkResponse(t, recorder, revoked)
+		})
+	}
+}
+
+// requirePasswordChangedAt checks that the response has the change time sent
+// to the store. The bcrypt hashes make wall clock comparisons too slow to be
+// reliable, so the time is captured in the store mock instead
+func requirePasswordChangedAt(t *testing.T, sent time.Time, got time.Time) {
+	require.False(t, sent.IsZero())
+	require.True(t, sent.Equal(got))
+}
+
+func TestChangeUserPasswordAPI(t *testing.T) {
+	user, password := randomUser(t)
+	newPassword := util.RandomPassword(16)
+
+	var passwordChangedAt time.Time
+
+	testCases := []struct {
+		name          string
+		body          map[string]any
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool)
+	}{
+		{
+			name: "OK",
+			body: map[string]any{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), EqUpdateUserPasswordParams(user.Username, newPassword)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+						passwordChangedAt = arg.PasswordChangedAt
+						updatedUser := user
+						updatedUser.HashedPassword = arg.HashedPassword
+						updatedUser.PasswordChangedAt = arg.PasswordChangedAt
+						return updatedUser, nil
+					})
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.True(t, revoked)
+
+				var res userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, res.Username)
+				requirePasswordChangedAt(t, passwordChangedAt, res.PasswordChangedAt)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			body: map[string]any{
+				"current_password": "incorrectPassword",
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeInvalidCredentials)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "TooShortPassword",
+			body: map[string]any{
+				"current_password": password,
+				"new_password":     "1234",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "NotFound",
+			body: map[string]any{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "InternalServerError",
+			body: map[string]any{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, revoked bool) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, revoked)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// marshal data body to json
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			url := "/users/password"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			server.router.ServeHTTP(recorder, request)
+
+			// the token used to change the password is issued before the change
+			revoked, err := server.denylist.IsRevoked(context.Background(), accessPayload)
+			require.NoError(t, err)
+
+			tc.checkResponse(t, recorder, revoked)
+		})
+	}
+}
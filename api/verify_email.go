@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/mail"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+const (
+	// verifyCodeSize is the number of random bytes of an email verification code
+	verifyCodeSize = 32
+
+	codeVerifyCodeInvalid = "verify_code_invalid"
+	codeEmailNotVerified  = "email_not_verified"
+)
+
+// sendVerifyEmail emails the link to verify the email of a new user. It runs
+// inside the transaction that creates the code, so the email gets its own
+// timeout to keep a stalled mail server from holding the transaction open
+func (server *Server) sendVerifyEmail(ctx context.Context, user db.User, verifyEmail db.VerifyEmail, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, server.mailTimeout())
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("id", strconv.FormatInt(verifyEmail.ID, 10))
+	query.Set("code", code)
+	link := fmt.Sprintf("%s/users/verify_email?%s", server.config.AppURL, query.Encode())
+
+	return server.mailer.SendEmail(ctx, mail.Email{
+		To:      []string{verifyEmail.Email},
+		Subject: "Verify your DevoraTasks email",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nThanks for signing up! Open this link to verify your email: %s\n\nThe link expires in %s.",
+			user.Username, link, server.config.VerifyEmailDuration,
+		),
+	})
+}
+
+type verifyEmailRequest struct {
+	ID   int64  `form:"id" requirements:"required;min=1"`
+	Code string `form:"code" requirements:"required"`
+}
+
+type verifyEmailResponse struct {
+	IsVerified bool `json:"is_verified"`
+}
+
+// verifyEmail marks the email of a user as verified using the link sent after
+// the signup, a link can be used only once
+func (server *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	err := util.ShouldBindQuery(r, &req)
+	if err != nil {
+		writeError(w, validationError(err))
+		return
+	}
+
+	arg := db.UseVerifyEmailParams{
+		ID:       req.ID,
+		CodeHash: util.HashToken(req.Code),
+	}
+
+	result, err := server.store.VerifyEmailTx(r.Context(), arg)
+	if err != nil {
+		if err == db.ErrInvalidVerifyCode {
+			writeError(w, newAPIError(http.StatusBadRequest, codeVerifyCodeInvalid, err.Error()))
+			return
+		}
+		writeError(w, internalError(r, err))
+		return
+	}
+
+	res := verifyEmailResponse{
+		IsVerified: result.User.IsEmailVerified,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(res))
+}
+
+// requireVerifiedEmail writes an error and returns false when the config asks
+// for verified emails and the email of the authenticated user isn't verified
+func (server *Server) requireVerifiedEmail(w http.ResponseWriter, r *http.Request) bool {
+	if !server.config.RequireVerifiedEmail {
+		return true
+	}
+
+	payload, valid := requirePayload(w, r)
+	if !valid {
+		return false
+	}
+
+	user, err := server.store.GetUser(r.Context(), payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, notFoundError("user"))
+			return false
+		}
+		writeError(w, internalError(r, err))
+		return false
+	}
+
+	if !user.IsEmailVerified {
+		writeError(w, newAPIError(http.StatusForbidden, codeEmailNotVerified, "the email of the user must be verified"))
+		return false
+	}
+
+	return true
+}
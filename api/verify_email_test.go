@@ -0,0 +1,331 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	code := util.RandomPassword(32)
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: url.Values{"id": {"1"}, "code": {code}},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UseVerifyEmailParams{
+					ID:       1,
+					CodeHash: util.HashToken(code),
+				}
+
+				verifiedUser := user
+				verifiedUser.IsEmailVerified = true
+
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: verifiedUser}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res verifyEmailResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &res)
+				require.NoError(t, err)
+				require.True(t, res.IsVerified)
+			},
+		},
+		{
+			name:  "InvalidCode",
+			query: url.Values{"id": {"1"}, "code": {code}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, db.ErrInvalidVerifyCode)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeVerifyCodeInvalid)
+			},
+		},
+		{
+			name:  "MissingCode",
+			query: url.Values{"id": {"1"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name:  "InvalidID",
+			query: url.Values{"id": {"0"}, "code": {code}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeValidationFailed)
+			},
+		},
+		{
+			name:  "InternalServerError",
+			query: url.Values{"id": {"1"}, "code": {code}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			// start test server and send request
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/verify_email?%s", tc.query.Encode())
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateUserSendsVerifyEmail(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var verifyEmail db.VerifyEmail
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+			require.WithinDuration(t, time.Now().Add(24*time.Hour), arg.VerifyExpiresAt, time.Second)
+			verifyEmail = db.VerifyEmail{
+				ID:       util.RandomID(),
+				Username: user.Username,
+				Email:    user.Email,
+				CodeHash: arg.VerifyCodeHash,
+			}
+			return db.CreateUserTxResult{User: user, VerifyEmail: verifyEmail}, arg.AfterCreate(user, verifyEmail)
+		})
+
+	server := newTestServer(t, store)
+	server.config.AppURL = "http://localhost:5000"
+	server.config.VerifyEmailDuration = 24 * time.Hour
+	mailer := server.mailer.(*testMailer)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(map[string]any{
+		"username": user.Username,
+		"email":    user.Email,
+		"password": password,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Len(t, mailer.emails, 1)
+	require.Equal(t, []string{user.Email}, mailer.emails[0].To)
+
+	// the email links to the verify endpoint with the plain code
+	var link *url.URL
+	for _, field := range strings.Fields(mailer.emails[0].Body) {
+		if strings.HasPrefix(field, server.config.AppURL) {
+			link, err = url.Parse(field)
+			require.NoError(t, err)
+		}
+	}
+	require.NotNil(t, link)
+	require.Equal(t, "/users/verify_email", link.Path)
+	require.Equal(t, strconv.FormatInt(verifyEmail.ID, 10), link.Query().Get("id"))
+	require.Equal(t, verifyEmail.CodeHash, util.HashToken(link.Query().Get("code")))
+}
+
+func TestCreateUserStalledMailer(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+			verifyEmail := db.VerifyEmail{ID: 1, Username: user.Username, Email: user.Email}
+			return db.CreateUserTxResult{}, arg.AfterCreate(user, verifyEmail)
+		})
+
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(map[string]any{
+		"username": user.Username,
+		"email":    user.Email,
+		"password": password,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	requireStalledMailerTimeout(t, server, request)
+}
+
+// requireStalledMailerTimeout sends a request with a mailer that is never
+// released. The mailer must get the mail timeout as its deadline, and the
+// request must give up once it passes instead of holding the transaction open
+func requireStalledMailerTimeout(t *testing.T, server *Server, request *http.Request) {
+	server.config.MailTimeout = 50 * time.Millisecond
+	mailer := &blockingMailer{release: make(chan struct{})}
+	server.mailer = mailer
+	recorder := httptest.NewRecorder()
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	requireErrorCode(t, recorder.Body, codeTimeout)
+
+	// the test server has no request timeout, so the deadline can only come
+	// from the mail timeout, set before the mailer was called
+	require.True(t, mailer.hasDeadline)
+	require.LessOrEqual(t, mailer.deadline.Sub(mailer.calledAt), server.config.MailTimeout)
+	require.Empty(t, mailer.emails)
+}
+
+func TestCreateTaskRequiresVerifiedEmail(t *testing.T) {
+	user, _ := randomUser(t)
+	task := randomTask(user.Username)
+
+	verifiedUser := user
+	verifiedUser.IsEmailVerified = true
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Verified",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(verifiedUser, nil)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateTaskTxResult{Task: task}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "NotVerified",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder.Body, codeEmailNotVerified)
+			},
+		},
+		{
+			name: "InternalServerError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					CreateTaskTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			data, err := json.Marshal(map[string]any{
+				"title":       task.Title,
+				"description": task.Description,
+			})
+			require.NoError(t, err)
+
+			server := newTestServer(t, store)
+			server.config.RequireVerifiedEmail = true
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
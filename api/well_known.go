@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/verifier"
+)
+
+// listPasetoKeys publishes the public keys that verify the access tokens so
+// other services can check them offline. The set is empty when the tokens are
+// signed with a symmetric key
+func (server *Server) listPasetoKeys(w http.ResponseWriter, r *http.Request) {
+	keys := []token.PublicKey{}
+	if publisher, ok := server.tokenMaker.(token.KeyPublisher); ok {
+		keys = publisher.PublicKeys()
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse(verifier.NewKeySet(keys)))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/DMV-Nicolas/DevoraTasks/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPasetoKeysAPI(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	publicMaker, err := token.NewPublicPasetoMaker(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		tokenMaker   token.Maker
+		expectedKeys int
+	}{
+		{name: "SymmetricKey", expectedKeys: 0},
+		{name: "PublicKey", tokenMaker: publicMaker, expectedKeys: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			if tc.tokenMaker != nil {
+				server.tokenMaker = tc.tokenMaker
+			}
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, verifier.KeysPath, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var set verifier.KeySet
+			err = json.Unmarshal(recorder.Body.Bytes(), &set)
+			require.NoError(t, err)
+			require.NotNil(t, set.Keys)
+			require.Len(t, set.Keys, tc.expectedKeys)
+
+			if tc.expectedKeys == 0 {
+				return
+			}
+
+			// a token of the server can be verified with the published keys only
+			keys, err := set.PublicKeys()
+			require.NoError(t, err)
+
+			accessToken, payload, err := server.tokenMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			gotPayload, err := verifier.New(keys...).Verify(accessToken)
+			require.NoError(t, err)
+			require.Equal(t, payload.ID, gotPayload.ID)
+		})
+	}
+}
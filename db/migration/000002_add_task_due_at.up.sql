@@ -0,0 +1,3 @@
+ALTER TABLE "tasks" ADD COLUMN "due_at" timestamptz;
+
+CREATE INDEX ON "tasks" ("owner", "due_at");
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE "tasks" DROP COLUMN IF EXISTS "priority";
+
+DROP TYPE IF EXISTS "task_priority";
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TYPE "task_priority" AS ENUM (
+  'low',
+  'medium',
+  'high',
+  'urgent'
+);
+
+ALTER TABLE "tasks" ADD COLUMN "priority" task_priority NOT NULL DEFAULT 'medium';
\ No newline at end of file
@@ -0,0 +1,22 @@
+CREATE TABLE "tags" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "task_tags" (
+  "task_id" bigint NOT NULL,
+  "tag_id" bigint NOT NULL,
+  PRIMARY KEY ("task_id", "tag_id")
+);
+
+CREATE UNIQUE INDEX ON "tags" ("owner", "name");
+
+CREATE INDEX ON "task_tags" ("tag_id");
+
+ALTER TABLE "tags" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "task_tags" ADD FOREIGN KEY ("task_id") REFERENCES "tasks" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "task_tags" ADD FOREIGN KEY ("tag_id") REFERENCES "tags" ("id") ON DELETE CASCADE;
\ No newline at end of file
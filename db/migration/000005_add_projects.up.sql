@@ -0,0 +1,18 @@
+CREATE TABLE "projects" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "color" varchar NOT NULL DEFAULT '',
+  "archived" boolean NOT NULL DEFAULT 'FALSE',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "projects" ("owner");
+
+ALTER TABLE "projects" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "tasks" ADD COLUMN "project_id" bigint;
+
+CREATE INDEX ON "tasks" ("project_id");
+
+ALTER TABLE "tasks" ADD FOREIGN KEY ("project_id") REFERENCES "projects" ("id") ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "tasks" ADD COLUMN "parent_id" bigint;
+
+CREATE INDEX ON "tasks" ("parent_id");
+
+ALTER TABLE "tasks" ADD FOREIGN KEY ("parent_id") REFERENCES "tasks" ("id") ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "tasks" ADD COLUMN "recurrence" varchar NOT NULL DEFAULT '';
\ No newline at end of file
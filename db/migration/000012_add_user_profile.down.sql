@@ -0,0 +1,5 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locale";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "timezone";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "full_name";
\ No newline at end of file
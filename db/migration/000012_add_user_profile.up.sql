@@ -0,0 +1,5 @@
+ALTER TABLE "users" ADD COLUMN "full_name" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "users" ADD COLUMN "timezone" varchar NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE "users" ADD COLUMN "locale" varchar NOT NULL DEFAULT 'en';
\ No newline at end of file
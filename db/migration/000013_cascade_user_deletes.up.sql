@@ -0,0 +1,23 @@
+ALTER TABLE "tasks" DROP CONSTRAINT IF EXISTS "tasks_owner_fkey";
+
+ALTER TABLE "tasks" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "tags" DROP CONSTRAINT IF EXISTS "tags_owner_fkey";
+
+ALTER TABLE "tags" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "projects" DROP CONSTRAINT IF EXISTS "projects_owner_fkey";
+
+ALTER TABLE "projects" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "sessions" DROP CONSTRAINT IF EXISTS "sessions_username_fkey";
+
+ALTER TABLE "sessions" ADD FOREIGN KEY ("username") REFERENCES "users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "password_resets" DROP CONSTRAINT IF EXISTS "password_resets_username_fkey";
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("username") REFERENCES "users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "verify_emails" DROP CONSTRAINT IF EXISTS "verify_emails_username_fkey";
+
+ALTER TABLE "verify_emails" ADD FOREIGN KEY ("username") REFERENCES "users" ("username") ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locked_at";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "role";
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'user';
+
+ALTER TABLE "users" ADD COLUMN "locked_at" timestamptz;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE "tasks" ADD COLUMN "next_task_id" bigint;
+
+CREATE INDEX ON "tasks" ("next_task_id");
+
+ALTER TABLE "tasks" ADD FOREIGN KEY ("next_task_id") REFERENCES "tasks" ("id") ON DELETE SET NULL;
\ No newline at end of file
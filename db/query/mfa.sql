@@ -0,0 +1,83 @@
+-- name: CreateUserTotp :one
+INSERT INTO user_totps (
+  username,
+  secret
+) VALUES (
+  $1, $2
+) ON CONFLICT (username) DO UPDATE
+SET secret = EXCLUDED.secret,
+  last_used_step = 0,
+  created_at = now()
+WHERE user_totps.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTotp :one
+SELECT * FROM user_totps
+WHERE username = $1 LIMIT 1;
+
+-- name: ConfirmUserTotp :one
+UPDATE user_totps
+SET confirmed_at = now(),
+  last_used_step = $2
+WHERE username = $1
+AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTotpStep :one
+UPDATE user_totps
+SET last_used_step = $2
+WHERE username = $1
+AND confirmed_at IS NOT NULL
+AND last_used_step < $2
+RETURNING *;
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username,
+  code_hash
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = now()
+WHERE username = $1
+AND code_hash = $2
+AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodesByUsername :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
+
+-- name: CreateMfaChallenge :one
+INSERT INTO mfa_challenges (
+  username,
+  token_hash,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: GetMfaChallenge :one
+SELECT * FROM mfa_challenges
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > now()
+LIMIT 1;
+
+-- name: AttemptMfaChallenge :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = sqlc.arg(id)
+AND attempts < sqlc.arg(max_attempts)
+AND used_at IS NULL
+RETURNING *;
+
+-- name: UseMfaChallenge :one
+UPDATE mfa_challenges
+SET used_at = now()
+WHERE id = $1
+AND used_at IS NULL
+RETURNING *;
\ No newline at end of file
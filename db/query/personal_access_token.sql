@@ -0,0 +1,35 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (
+  id,
+  username,
+  name,
+  token_hash,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetPersonalAccessToken :one
+SELECT * FROM personal_access_tokens
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetPersonalAccessTokenByHash :one
+SELECT * FROM personal_access_tokens
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: ListPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens
+WHERE username = $1
+ORDER BY created_at;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = now()
+WHERE id = $1;
+
+-- name: DeletePersonalAccessToken :exec
+DELETE FROM personal_access_tokens
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- name: CreateProject :one
+INSERT INTO projects (owner,name,color)
+VALUES ($1,$2,$3)
+RETURNING *;
+
+-- name: GetProject :one
+SELECT * FROM projects
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListProjects :many
+SELECT * FROM projects
+WHERE owner = $1
+AND archived = $2
+ORDER BY id
+OFFSET $3
+LIMIT $4;
+
+-- name: ListProjectsByOwner :many
+SELECT * FROM projects
+WHERE owner = $1
+ORDER BY id;
+
+-- name: UpdateProject :one
+UPDATE projects
+SET name = $2,
+color = $3,
+archived = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteProject :exec
+DELETE FROM projects
+WHERE id = $1;
+
+-- name: DeleteProjectsByOwner :exec
+DELETE FROM projects
+WHERE owner = $1;
\ No newline at end of file
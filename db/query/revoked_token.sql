@@ -0,0 +1,25 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (id, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: IsTokenRevoked :one
+SELECT EXISTS (
+  SELECT 1 FROM revoked_tokens
+  WHERE id = sqlc.arg(id)
+) OR NOT EXISTS (
+  SELECT 1 FROM users
+  WHERE username = sqlc.arg(username)
+) OR EXISTS (
+  SELECT 1 FROM users
+  WHERE username = sqlc.arg(username)
+  AND (
+    password_changed_at > sqlc.arg(issued_at)::timestamptz
+    OR created_at > sqlc.arg(issued_at)::timestamptz
+    OR tokens_valid_after > sqlc.arg(issued_at)::timestamptz
+  )
+) AS revoked;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now();
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+  id,
+  username,
+  refresh_token,
+  user_agent,
+  client_ip,
+  is_blocked,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListSessionsByUsername :many
+SELECT * FROM sessions
+WHERE username = $1
+ORDER BY created_at;
+
+-- name: DeleteSessionsByUsername :exec
+DELETE FROM sessions
+WHERE username = $1;
+
+-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = TRUE
+WHERE id = $1
+RETURNING *;
+
+-- name: BlockSessionsByUsername :many
+UPDATE sessions
+SET is_blocked = TRUE
+WHERE username = $1
+AND is_blocked = FALSE
+AND expires_at > now()
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,56 @@
+-- name: CreateTag :one
+INSERT INTO tags (owner,name)
+VALUES ($1,$2)
+RETURNING *;
+
+-- name: GetTag :one
+SELECT * FROM tags
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListTags :many
+SELECT * FROM tags
+WHERE owner = $1
+ORDER BY name
+OFFSET $2
+LIMIT $3;
+
+-- name: ListTagsByOwner :many
+SELECT * FROM tags
+WHERE owner = $1
+ORDER BY id;
+
+-- name: ListTagsByIDs :many
+SELECT * FROM tags
+WHERE owner = sqlc.arg(owner)
+AND id = ANY(sqlc.arg(ids)::bigint[])
+ORDER BY name;
+
+-- name: DeleteTag :exec
+DELETE FROM tags
+WHERE id = $1;
+
+-- name: AddTaskTag :exec
+INSERT INTO task_tags (task_id,tag_id)
+VALUES ($1,$2)
+ON CONFLICT DO NOTHING;
+
+-- name: ListTaskTags :many
+SELECT tags.* FROM tags
+JOIN task_tags ON task_tags.tag_id = tags.id
+WHERE task_tags.task_id = $1
+ORDER BY tags.name;
+
+-- name: ListTaskTagsByOwner :many
+SELECT task_tags.* FROM task_tags
+JOIN tasks ON tasks.id = task_tags.task_id
+WHERE tasks.owner = $1
+ORDER BY task_tags.task_id, task_tags.tag_id;
+
+-- name: DeleteTaskTags :exec
+DELETE FROM task_tags
+WHERE task_id = $1;
+
+-- name: DeleteTagsByOwner :exec
+DELETE FROM tags
+WHERE owner = $1;
\ No newline at end of file
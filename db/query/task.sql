@@ -1,6 +1,6 @@
 -- name: CreateTask :one
-INSERT INTO tasks (owner,title,description)
-VALUES ($1,$2,$3)
+INSERT INTO tasks (owner,title,description,due_at,priority,project_id,parent_id,recurrence)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
 RETURNING *;
 
 -- name: GetTask :one
@@ -8,21 +8,116 @@ SELECT * FROM tasks
 WHERE id = $1 
 LIMIT 1;
 
+-- name: GetTaskForUpdate :one
+SELECT * FROM tasks
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
 -- name: ListTasks :many
 SELECT * FROM tasks
+WHERE owner = sqlc.arg(owner)
+AND (sqlc.narg(due_before)::timestamptz IS NULL OR due_at < sqlc.narg(due_before))
+AND (sqlc.narg(due_after)::timestamptz IS NULL OR due_at > sqlc.narg(due_after))
+AND (NOT sqlc.arg(overdue)::boolean OR (due_at < now() AND NOT done))
+AND (coalesce(cardinality(sqlc.arg(tags)::varchar[]), 0) = 0 OR (
+  SELECT count(DISTINCT tags.name) FROM task_tags
+  JOIN tags ON tags.id = task_tags.tag_id
+  WHERE task_tags.task_id = tasks.id
+  AND tags.name = ANY(sqlc.arg(tags)::varchar[])
+) >= CASE WHEN sqlc.arg(match_all_tags)::boolean THEN cardinality(sqlc.arg(tags)::varchar[]) ELSE 1 END)
+AND (sqlc.narg(project_id)::bigint IS NULL OR project_id = sqlc.narg(project_id))
+ORDER BY id
+OFFSET sqlc.arg('offset')
+LIMIT sqlc.arg('limit');
+
+-- name: ListTasksByOwner :many
+SELECT * FROM tasks
 WHERE owner = $1
+ORDER BY id;
+
+-- name: ListAllTasks :many
+SELECT * FROM tasks
+WHERE sqlc.arg(owner)::varchar = ''
+OR owner = sqlc.arg(owner)
 ORDER BY id
-OFFSET $2
-LIMIT $3;
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
 
 -- name: UpdateTask :one
 UPDATE tasks
 SET title = $2,
 description = $3,
-done = $4
+done = $4,
+due_at = $5,
+priority = $6,
+project_id = $7,
+parent_id = $8,
+recurrence = $9
+WHERE id = $1
+RETURNING *;
+
+-- name: SetTaskNextTask :one
+UPDATE tasks
+SET next_task_id = $2
 WHERE id = $1
 RETURNING *;
 
+-- name: ListSubtasks :many
+SELECT * FROM tasks
+WHERE parent_id = sqlc.arg(parent_id)::bigint
+ORDER BY id;
+
+-- name: GetTaskProgress :one
+SELECT count(*) AS total,
+count(*) FILTER (WHERE done) AS done
+FROM tasks
+WHERE parent_id = sqlc.arg(parent_id)::bigint;
+
+-- name: ListTaskProgress :many
+SELECT parent_id::bigint AS parent_id,
+count(*) AS total,
+count(*) FILTER (WHERE done) AS done
+FROM tasks
+WHERE parent_id = ANY(sqlc.arg(parent_ids)::bigint[])
+GROUP BY parent_id;
+
+-- name: IsTaskDescendant :one
+WITH RECURSIVE descendants AS (
+  SELECT id FROM tasks
+  WHERE parent_id = sqlc.arg(ancestor_id)::bigint
+  UNION
+  SELECT tasks.id FROM tasks
+  JOIN descendants ON tasks.parent_id = descendants.id
+)
+SELECT EXISTS (
+  SELECT 1 FROM descendants
+  WHERE id = sqlc.arg(task_id)::bigint
+);
+
+-- name: CompleteSubtasks :exec
+WITH RECURSIVE descendants AS (
+  SELECT id FROM tasks
+  WHERE parent_id = sqlc.arg(parent_id)::bigint
+  UNION
+  SELECT tasks.id FROM tasks
+  JOIN descendants ON tasks.parent_id = descendants.id
+)
+UPDATE tasks
+SET done = TRUE
+WHERE id IN (SELECT id FROM descendants);
+
 -- name: DeleteTask :exec
 DELETE FROM tasks
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: MoveTasks :many
+UPDATE tasks
+SET project_id = sqlc.narg(project_id)
+WHERE owner = sqlc.arg(owner)
+AND id = ANY(sqlc.arg(ids)::bigint[])
+RETURNING *;
+
+-- name: DeleteTasksByOwner :exec
+DELETE FROM tasks
+WHERE owner = $1;
\ No newline at end of file
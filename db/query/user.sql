@@ -8,11 +8,37 @@ SELECT * FROM users
 WHERE username = $1 
 LIMIT 1;
 
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1
+LIMIT 1;
+
 -- name: ListUsers :many
 SELECT * FROM users
+WHERE sqlc.arg(search)::varchar = ''
+OR username ILIKE '%' || sqlc.arg(search) || '%'
+OR email ILIKE '%' || sqlc.arg(search) || '%'
 ORDER BY username
-LIMIT $1
-OFFSET $2;
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: LockUser :one
+UPDATE users
+SET locked_at = COALESCE(locked_at, now()),
+tokens_valid_after = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: RevokeUserTokens :exec
+UPDATE users
+SET tokens_valid_after = GREATEST(tokens_valid_after, sqlc.arg(issued_before)::timestamptz)
+WHERE username = sqlc.arg(username);
+
+-- name: UnlockUser :one
+UPDATE users
+SET locked_at = NULL
+WHERE username = $1
+RETURNING *;
 
 -- name: UpdateUser :one
 UPDATE users
@@ -21,6 +47,30 @@ hashed_password = $3
 WHERE username = $1
 RETURNING *;
 
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+password_changed_at = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET email = $2,
+full_name = $3,
+timezone = $4,
+locale = $5,
+is_email_verified = $6
+WHERE username = $1
+RETURNING *;
+
 -- name: DeleteUser :exec
 DELETE FROM users
-WHERE username = $1;
\ No newline at end of file
+WHERE username = $1;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = TRUE
+WHERE username = $1
+AND email = $2
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  username,
+  email,
+  code_hash,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: UseVerifyEmail :one
+UPDATE verify_emails
+SET is_used = TRUE
+WHERE id = $1
+AND code_hash = $2
+AND is_used = FALSE
+AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteVerifyEmailsByUsername :exec
+DELETE FROM verify_emails
+WHERE username = $1;
\ No newline at end of file
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/totp"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomUserTotp(t *testing.T, username string) UserTotp {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	arg := CreateUserTotpParams{
+		Username: username,
+		Secret:   secret,
+	}
+
+	userTotp, err := testQueries.CreateUserTotp(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, userTotp.Username)
+	require.Equal(t, arg.Secret, userTotp.Secret)
+	require.Zero(t, userTotp.LastUsedStep)
+	require.Nil(t, userTotp.ConfirmedAt)
+	require.NotZero(t, userTotp.CreatedAt)
+
+	return userTotp
+}
+
+func createConfirmedUserTotp(t *testing.T, username string) UserTotp {
+	userTotp := createRandomUserTotp(t, username)
+
+	confirmed, err := testQueries.ConfirmUserTotp(context.Background(), ConfirmUserTotpParams{
+		Username:     username,
+		LastUsedStep: totp.Step(time.Now()),
+	})
+	require.NoError(t, err)
+	require.Equal(t, userTotp.Secret, confirmed.Secret)
+	require.NotNil(t, confirmed.ConfirmedAt)
+
+	return confirmed
+}
+
+func createRandomMfaChallenge(t *testing.T, username string, expiresAt time.Time) (MfaChallenge, string) {
+	token, err := util.SecureToken(32)
+	require.NoError(t, err)
+
+	arg := CreateMfaChallengeParams{
+		Username:  username,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+
+	challenge, err := testQueries.CreateMfaChallenge(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, challenge.ID)
+	require.Equal(t, arg.Username, challenge.Username)
+	require.Equal(t, arg.TokenHash, challenge.TokenHash)
+	require.Zero(t, challenge.Attempts)
+	require.WithinDuration(t, arg.ExpiresAt, challenge.ExpiresAt, time.Second)
+	require.Nil(t, challenge.UsedAt)
+
+	return challenge, token
+}
+
+func TestCreateUserTotp(t *testing.T) {
+	user := createRandomUser(t)
+	userTotp1 := createRandomUserTotp(t, user.Username)
+
+	// an unconfirmed secret can be replaced
+	userTotp2 := createRandomUserTotp(t, user.Username)
+	require.NotEqual(t, userTotp1.Secret, userTotp2.Secret)
+
+	userTotp3, err := testQueries.GetUserTotp(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, userTotp2.Secret, userTotp3.Secret)
+}
+
+func TestConfirmUserTotp(t *testing.T) {
+	user := createRandomUser(t)
+	userTotp := createConfirmedUserTotp(t, user.Username)
+
+	_, err := testQueries.ConfirmUserTotp(context.Background(), ConfirmUserTotpParams{
+		Username:     user.Username,
+		LastUsedStep: userTotp.LastUsedStep + 1,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// a confirmed secret can't be replaced
+	_, err = testQueries.CreateUserTotp(context.Background(), CreateUserTotpParams{
+		Username: user.Username,
+		Secret:   "JBSWY3DPEHPK3PXP",
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestUseTotpStep(t *testing.T) {
+	user := createRandomUser(t)
+	userTotp1 := createConfirmedUserTotp(t, user.Username)
+
+	arg := UseTotpStepParams{
+		Username:     user.Username,
+		LastUsedStep: userTotp1.LastUsedStep + 1,
+	}
+
+	userTotp2, err := testQueries.UseTotpStep(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.LastUsedStep, userTotp2.LastUsedStep)
+
+	// a code can't be used twice
+	_, err = testQueries.UseTotpStep(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// nor can an older one
+	arg.LastUsedStep--
+	_, err = testQueries.UseTotpStep(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// unconfirmed secrets can't be used to log in
+	other := createRandomUser(t)
+	createRandomUserTotp(t, other.Username)
+	_, err = testQueries.UseTotpStep(context.Background(), UseTotpStepParams{
+		Username:     other.Username,
+		LastUsedStep: 1,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := CreateRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: util.HashToken(util.RandomPassword(10)),
+	}
+
+	code1, err := testQueries.CreateRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, code1.ID)
+	require.Nil(t, code1.UsedAt)
+
+	code2, err := testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams(arg))
+	require.NoError(t, err)
+	require.Equal(t, code1.ID, code2.ID)
+	require.NotNil(t, code2.UsedAt)
+
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams(arg))
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	// the codes only work for their own user
+	other := createRandomUser(t)
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username: other.Username,
+		CodeHash: arg.CodeHash,
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestMfaChallenge(t *testing.T) {
+	user := createRandomUser(t)
+	challenge1, token := createRandomMfaChallenge(t, user.Username, time.Now().Add(time.Minute))
+
+	challenge2, err := testQueries.GetMfaChallenge(context.Background(), util.HashToken(token))
+	require.NoError(t, err)
+	require.Equal(t, challenge1.ID, challenge2.ID)
+
+	arg := AttemptMfaChallengeParams{
+		ID:          challenge1.ID,
+		MaxAttempts: 2,
+	}
+	for i := 1; i <= 2; i++ {
+		challenge2, err = testQueries.AttemptMfaChallenge(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, int32(i), challenge2.Attempts)
+	}
+
+	// no attempts are counted over the limit
+	_, err = testQueries.AttemptMfaChallenge(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	challenge2, err = testQueries.UseMfaChallenge(context.Background(), challenge1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, challenge2.UsedAt)
+
+	// a challenge can be used only once
+	_, err = testQueries.UseMfaChallenge(context.Background(), challenge1.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetMfaChallenge(context.Background(), util.HashToken(token))
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	expired, _ := createRandomMfaChallenge(t, user.Username, time.Now().Add(-time.Minute))
+	_, err = testQueries.GetMfaChallenge(context.Background(), expired.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestEnrollTotpTx(t *testing.T) {
+	user := createRandomUser(t)
+
+	codes := []string{util.RandomPassword(10), util.RandomPassword(10)}
+	arg := EnrollTotpTxParams{
+		CreateUserTotpParams: CreateUserTotpParams{
+			Username: user.Username,
+			Secret:   "JBSWY3DPEHPK3PXP",
+		},
+		RecoveryCodeHashes: []string{util.HashToken(codes[0]), util.HashToken(codes[1])},
+	}
+
+	userTotp, err := testQueries.EnrollTotpTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Secret, userTotp.Secret)
+	require.Nil(t, userTotp.ConfirmedAt)
+
+	// enrolling again replaces the recovery codes
+	arg.RecoveryCodeHashes = []string{util.HashToken(util.RandomPassword(10))}
+	_, err = testQueries.EnrollTotpTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: util.HashToken(codes[0]),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: arg.RecoveryCodeHashes[0],
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.ConfirmUserTotp(context.Background(), ConfirmUserTotpParams{
+		Username:     user.Username,
+		LastUsedStep: 1,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.EnrollTotpTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrTotpEnabled)
+}
+
+func TestUseMfaChallengeTx(t *testing.T) {
+	user := createRandomUser(t)
+	createConfirmedUserTotp(t, user.Username)
+
+	codeHash := util.HashToken(util.RandomPassword(10))
+	_, err := testQueries.CreateRecoveryCode(context.Background(), CreateRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: codeHash,
+	})
+	require.NoError(t, err)
+
+	challenge1, _ := createRandomMfaChallenge(t, user.Username, time.Now().Add(time.Minute))
+	arg := UseMfaChallengeTxParams{
+		ChallengeID:      challenge1.ID,
+		Username:         user.Username,
+		RecoveryCodeHash: codeHash,
+	}
+
+	challenge2, err := testQueries.UseMfaChallengeTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, challenge1.ID, challenge2.ID)
+	require.NotNil(t, challenge2.UsedAt)
+
+	_, err = testQueries.UseMfaChallengeTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidMfaToken)
+
+	// the challenge isn't used when the code was already spent
+	challenge3, _ := createRandomMfaChallenge(t, user.Username, time.Now().Add(time.Minute))
+	arg.ChallengeID = challenge3.ID
+	_, err = testQueries.UseMfaChallengeTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidMfaCode)
+
+	// the challenge was rolled back, so it can still be used with a valid code
+	codeHash = util.HashToken(util.RandomPassword(10))
+	_, err = testQueries.CreateRecoveryCode(context.Background(), CreateRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: codeHash,
+	})
+	require.NoError(t, err)
+
+	arg.RecoveryCodeHash = codeHash
+	_, err = testQueries.UseMfaChallengeTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	// the totp step can't go back
+	challenge4, _ := createRandomMfaChallenge(t, user.Username, time.Now().Add(time.Minute))
+	_, err = testQueries.UseMfaChallengeTx(context.Background(), UseMfaChallengeTxParams{
+		ChallengeID: challenge4.ID,
+		Username:    user.Username,
+		TotpStep:    1,
+	})
+	require.ErrorIs(t, err, ErrInvalidMfaCode)
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomPasswordReset(t *testing.T, username string, expiresAt time.Time) (PasswordReset, string) {
+	code, err := util.SecureToken(32)
+	require.NoError(t, err)
+
+	arg := CreatePasswordResetParams{
+		Username:  username,
+		CodeHash:  util.HashToken(code),
+		ExpiresAt: expiresAt,
+	}
+
+	reset, err := testQueries.CreatePasswordReset(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, reset.ID)
+	require.Equal(t, arg.Username, reset.Username)
+	require.Equal(t, arg.CodeHash, reset.CodeHash)
+	require.WithinDuration(t, arg.ExpiresAt, reset.ExpiresAt, time.Second)
+	require.Nil(t, reset.UsedAt)
+	require.NotZero(t, reset.CreatedAt)
+
+	return reset, code
+}
+
+func TestCreatePasswordReset(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomPasswordReset(t, user.Username, time.Now().Add(time.Minute))
+}
+
+func TestUsePasswordReset(t *testing.T) {
+	user := createRandomUser(t)
+	reset1, _ := createRandomPasswordReset(t, user.Username, time.Now().Add(time.Minute))
+
+	reset2, err := testQueries.UsePasswordReset(context.Background(), reset1.CodeHash)
+	require.NoError(t, err)
+	require.Equal(t, reset1.ID, reset2.ID)
+	require.NotNil(t, reset2.UsedAt)
+
+	// a code can be used only once
+	_, err = testQueries.UsePasswordReset(context.Background(), reset1.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	expired, _ := createRandomPasswordReset(t, user.Username, time.Now().Add(-time.Minute))
+	_, err = testQueries.UsePasswordReset(context.Background(), expired.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestDeletePasswordResetsByUsername(t *testing.T) {
+	user := createRandomUser(t)
+	reset, _ := createRandomPasswordReset(t, user.Username, time.Now().Add(time.Minute))
+
+	err := testQueries.DeletePasswordResetsByUsername(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	_, err = testQueries.UsePasswordReset(context.Background(), reset.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	user1 := createRandomUser(t)
+	session := createRandomSession(t, user1.Username)
+	_, code := createRandomPasswordReset(t, user1.Username, time.Now().Add(time.Minute))
+	_, otherCode := createRandomPasswordReset(t, user1.Username, time.Now().Add(time.Minute))
+
+	hashedPassword, err := util.HashPassword(util.RandomPassword(16))
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		CodeHash:          util.HashToken(code),
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	result, err := testQueries.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, result.User.Username)
+	require.Equal(t, hashedPassword, result.User.HashedPassword)
+	require.WithinDuration(t, arg.PasswordChangedAt, result.User.PasswordChangedAt, time.Second)
+	require.Len(t, result.Sessions, 1)
+	require.Equal(t, session.ID, result.Sessions[0].ID)
+	require.True(t, result.Sessions[0].IsBlocked)
+
+	_, err = testQueries.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidResetCode)
+
+	arg.CodeHash = util.HashToken(otherCode)
+	_, err = testQueries.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidResetCode)
+
+	user2, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, user2.HashedPassword)
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomPersonalAccessToken(t *testing.T, username string) PersonalAccessToken {
+	expiresAt := time.Now().Add(time.Hour)
+	arg := CreatePersonalAccessTokenParams{
+		ID:        uuid.New(),
+		Username:  username,
+		Name:      util.RandomUsername(),
+		TokenHash: util.HashToken(util.RandomPassword(32)),
+		Scopes:    []string{"tasks:read", "tasks:write"},
+		ExpiresAt: &expiresAt,
+	}
+
+	pat, err := testQueries.CreatePersonalAccessToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, pat.ID)
+	require.Equal(t, arg.Username, pat.Username)
+	require.Equal(t, arg.Name, pat.Name)
+	require.Equal(t, arg.TokenHash, pat.TokenHash)
+	require.Equal(t, arg.Scopes, pat.Scopes)
+	require.NotNil(t, pat.ExpiresAt)
+	require.WithinDuration(t, expiresAt, *pat.ExpiresAt, time.Second)
+	require.Nil(t, pat.LastUsedAt)
+	require.NotZero(t, pat.CreatedAt)
+
+	return pat
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	user := createRandomUser(t)
+	pat := createRandomPersonalAccessToken(t, user.Username)
+
+	// the names are unique for each user
+	_, err := testQueries.CreatePersonalAccessToken(context.Background(), CreatePersonalAccessTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		Name:      pat.Name,
+		TokenHash: util.HashToken(util.RandomPassword(32)),
+		Scopes:    []string{},
+	})
+	require.Equal(t, UniqueViolation, ErrorCode(err))
+}
+
+func TestGetPersonalAccessToken(t *testing.T) {
+	user := createRandomUser(t)
+	pat1 := createRandomPersonalAccessToken(t, user.Username)
+
+	pat2, err := testQueries.GetPersonalAccessToken(context.Background(), pat1.ID)
+	require.NoError(t, err)
+	require.Equal(t, pat1.TokenHash, pat2.TokenHash)
+
+	pat2, err = testQueries.GetPersonalAccessTokenByHash(context.Background(), pat1.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, pat1.ID, pat2.ID)
+	require.Equal(t, pat1.Scopes, pat2.Scopes)
+}
+
+func TestListPersonalAccessTokens(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomPersonalAccessToken(t, user.Username)
+	}
+
+	pats, err := testQueries.ListPersonalAccessTokens(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, pats, 3)
+	for _, pat := range pats {
+		require.Equal(t, user.Username, pat.Username)
+	}
+}
+
+func TestTouchPersonalAccessToken(t *testing.T) {
+	user := createRandomUser(t)
+	pat1 := createRandomPersonalAccessToken(t, user.Username)
+
+	err := testQueries.TouchPersonalAccessToken(context.Background(), pat1.ID)
+	require.NoError(t, err)
+
+	pat2, err := testQueries.GetPersonalAccessToken(context.Background(), pat1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, pat2.LastUsedAt)
+	require.WithinDuration(t, time.Now(), *pat2.LastUsedAt, time.Second)
+}
+
+func TestDeletePersonalAccessToken(t *testing.T) {
+	user := createRandomUser(t)
+	pat := createRandomPersonalAccessToken(t, user.Username)
+
+	err := testQueries.DeletePersonalAccessToken(context.Background(), pat.ID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetPersonalAccessTokenByHash(context.Background(), pat.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomProject(t *testing.T, owner string) Project {
+	arg := CreateProjectParams{
+		Owner: owner,
+		Name:  util.RandomPassword(12),
+		Color: "#" + util.RandomPassword(6),
+	}
+
+	project, err := testQueries.CreateProject(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, project)
+
+	require.Equal(t, arg.Owner, project.Owner)
+	require.Equal(t, arg.Name, project.Name)
+	require.Equal(t, arg.Color, project.Color)
+	require.False(t, project.Archived)
+	require.NotZero(t, project.ID)
+	require.NotZero(t, project.CreatedAt)
+
+	return project
+}
+
+func TestCreateProject(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomProject(t, user.Username)
+}
+
+func TestGetProject(t *testing.T) {
+	user := createRandomUser(t)
+	project1 := createRandomProject(t, user.Username)
+
+	project2, err := testQueries.GetProject(context.Background(), project1.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, project2)
+
+	require.Equal(t, project1.ID, project2.ID)
+	require.Equal(t, project1.Owner, project2.Owner)
+	require.Equal(t, project1.Name, project2.Name)
+	require.Equal(t, project1.Color, project2.Color)
+	require.Equal(t, project1.Archived, project2.Archived)
+	require.WithinDuration(t, project1.CreatedAt, project2.CreatedAt, time.Second)
+}
+
+func TestListProjects(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 5; i++ {
+		createRandomProject(t, user.Username)
+	}
+
+	arg := ListProjectsParams{
+		Owner:  user.Username,
+		Limit:  5,
+		Offset: 0,
+	}
+
+	projects, err := testQueries.ListProjects(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, projects, 5)
+
+	for _, project := range projects {
+		require.Equal(t, user.Username, project.Owner)
+		require.False(t, project.Archived)
+	}
+}
+
+func TestUpdateProject(t *testing.T) {
+	user := createRandomUser(t)
+	project1 := createRandomProject(t, user.Username)
+
+	arg := UpdateProjectParams{
+		ID:       project1.ID,
+		Name:     util.RandomPassword(12),
+		Color:    project1.Color,
+		Archived: true,
+	}
+
+	project2, err := testQueries.UpdateProject(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, project2)
+
+	require.Equal(t, project1.ID, project2.ID)
+	require.Equal(t, project1.Owner, project2.Owner)
+	require.Equal(t, arg.Name, project2.Name)
+	require.Equal(t, arg.Color, project2.Color)
+	require.True(t, project2.Archived)
+	require.WithinDuration(t, project1.CreatedAt, project2.CreatedAt, time.Second)
+}
+
+func TestListProjectTasks(t *testing.T) {
+	user := createRandomUser(t)
+	project := createRandomProject(t, user.Username)
+
+	for i := 0; i < 3; i++ {
+		task := createRandomTaskWithOwner(t, user.Username, "Project task")
+		_, err := testQueries.UpdateTask(context.Background(), UpdateTaskParams{
+			ID:          task.ID,
+			Title:       task.Title,
+			Description: task.Description,
+			Done:        task.Done,
+			DueAt:       task.DueAt,
+			Priority:    task.Priority,
+			ProjectID:   &project.ID,
+		})
+		require.NoError(t, err)
+	}
+	createRandomTaskWithOwner(t, user.Username, "Loose task")
+
+	arg := ListTasksParams{
+		Owner:     user.Username,
+		ProjectID: &project.ID,
+		Limit:     5,
+		Offset:    0,
+	}
+
+	tasks, err := testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+
+	for _, task := range tasks {
+		require.NotNil(t, task.ProjectID)
+		require.Equal(t, project.ID, *task.ProjectID)
+	}
+
+	err = testQueries.DeleteProject(context.Background(), project.ID)
+	require.NoError(t, err)
+
+	tasks, err = testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+}
+
+func TestDeleteProject(t *testing.T) {
+	user := createRandomUser(t)
+	project1 := createRandomProject(t, user.Username)
+
+	err := testQueries.DeleteProject(context.Background(), project1.ID)
+	require.NoError(t, err)
+
+	project2, err := testQueries.GetProject(context.Background(), project1.ID)
+	require.Error(t, err)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.Empty(t, project2)
+}
@@ -6,19 +6,89 @@ package db
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
+	AddTaskTag(ctx context.Context, arg AddTaskTagParams) error
+	AttemptMfaChallenge(ctx context.Context, arg AttemptMfaChallengeParams) (MfaChallenge, error)
+	BlockSession(ctx context.Context, id uuid.UUID) (Session, error)
+	BlockSessionsByUsername(ctx context.Context, username string) ([]Session, error)
+	CompleteSubtasks(ctx context.Context, parentID int64) error
+	ConfirmUserTotp(ctx context.Context, arg ConfirmUserTotpParams) (UserTotp, error)
+	CreateMfaChallenge(ctx context.Context, arg CreateMfaChallengeParams) (MfaChallenge, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	CreatePersonalAccessToken(ctx context.Context, arg CreatePersonalAccessTokenParams) (PersonalAccessToken, error)
+	CreateProject(ctx context.Context, arg CreateProjectParams) (Project, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateTag(ctx context.Context, arg CreateTagParams) (Tag, error)
 	CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserTotp(ctx context.Context, arg CreateUserTotpParams) (UserTotp, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	DeletePasswordResetsByUsername(ctx context.Context, username string) error
+	DeletePersonalAccessToken(ctx context.Context, id uuid.UUID) error
+	DeleteProject(ctx context.Context, id int64) error
+	DeleteProjectsByOwner(ctx context.Context, owner string) error
+	DeleteRecoveryCodesByUsername(ctx context.Context, username string) error
+	DeleteSessionsByUsername(ctx context.Context, username string) error
+	DeleteTag(ctx context.Context, id int64) error
+	DeleteTagsByOwner(ctx context.Context, owner string) error
 	DeleteTask(ctx context.Context, id int64) error
+	DeleteTaskTags(ctx context.Context, taskID int64) error
+	DeleteTasksByOwner(ctx context.Context, owner string) error
 	DeleteUser(ctx context.Context, username string) error
+	DeleteVerifyEmailsByUsername(ctx context.Context, username string) error
+	GetMfaChallenge(ctx context.Context, tokenHash string) (MfaChallenge, error)
+	GetPersonalAccessToken(ctx context.Context, id uuid.UUID) (PersonalAccessToken, error)
+	GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (PersonalAccessToken, error)
+	GetProject(ctx context.Context, id int64) (Project, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetTag(ctx context.Context, id int64) (Tag, error)
 	GetTask(ctx context.Context, id int64) (Task, error)
+	GetTaskForUpdate(ctx context.Context, id int64) (Task, error)
+	GetTaskProgress(ctx context.Context, parentID int64) (GetTaskProgressRow, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserTotp(ctx context.Context, username string) (UserTotp, error)
+	IsTaskDescendant(ctx context.Context, arg IsTaskDescendantParams) (bool, error)
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
+	ListAllTasks(ctx context.Context, arg ListAllTasksParams) ([]Task, error)
+	ListPersonalAccessTokens(ctx context.Context, username string) ([]PersonalAccessToken, error)
+	ListProjects(ctx context.Context, arg ListProjectsParams) ([]Project, error)
+	ListProjectsByOwner(ctx context.Context, owner string) ([]Project, error)
+	ListSessionsByUsername(ctx context.Context, username string) ([]Session, error)
+	ListSubtasks(ctx context.Context, parentID int64) ([]Task, error)
+	ListTags(ctx context.Context, arg ListTagsParams) ([]Tag, error)
+	ListTagsByIDs(ctx context.Context, arg ListTagsByIDsParams) ([]Tag, error)
+	ListTagsByOwner(ctx context.Context, owner string) ([]Tag, error)
+	ListTaskProgress(ctx context.Context, parentIds []int64) ([]ListTaskProgressRow, error)
+	ListTaskTags(ctx context.Context, taskID int64) ([]Tag, error)
+	ListTaskTagsByOwner(ctx context.Context, owner string) ([]TaskTag, error)
 	ListTasks(ctx context.Context, arg ListTasksParams) ([]Task, error)
+	ListTasksByOwner(ctx context.Context, owner string) ([]Task, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	LockUser(ctx context.Context, username string) (User, error)
+	MoveTasks(ctx context.Context, arg MoveTasksParams) ([]Task, error)
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	SetTaskNextTask(ctx context.Context, arg SetTaskNextTaskParams) (Task, error)
+	TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error
+	UnlockUser(ctx context.Context, username string) (User, error)
+	UpdateProject(ctx context.Context, arg UpdateProjectParams) (Project, error)
 	UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error)
+	UseMfaChallenge(ctx context.Context, id int64) (MfaChallenge, error)
+	UsePasswordReset(ctx context.Context, codeHash string) (PasswordReset, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error)
+	UseTotpStep(ctx context.Context, arg UseTotpStepParams) (UserTotp, error)
+	UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmail, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
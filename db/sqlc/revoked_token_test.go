@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	isRevokedArg := IsTokenRevokedParams{
+		ID:       arg.ID,
+		Username: createRandomUser(t).Username,
+		IssuedAt: time.Now(),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), isRevokedArg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	// revoking a token twice is not an error
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), isRevokedArg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestIsTokenRevokedByPasswordChange(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: time.Now(),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	_, err = testQueries.UpdateUserPassword(context.Background(), UpdateUserPasswordParams{
+		Username:          user.Username,
+		HashedPassword:    user.HashedPassword,
+		PasswordChangedAt: arg.IssuedAt.Add(time.Second),
+	})
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// tokens issued after the change are still valid
+	arg.IssuedAt = arg.IssuedAt.Add(time.Minute)
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestIsTokenRevokedByReusedUsername(t *testing.T) {
+	user := createRandomUser(t)
+
+	// a token issued before the user exists belongs to a deleted user with the same username
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: user.CreatedAt.Add(-time.Minute),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	arg.IssuedAt = user.CreatedAt.Add(time.Second)
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestIsTokenRevokedByDeletedUser(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: time.Now(),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	err = testQueries.DeleteUser(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	user := createRandomUser(t)
+	expired := RevokeTokenParams{
+		ID:        uuid.New(),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	active := RevokeTokenParams{
+		ID:        uuid.New(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	for _, arg := range []RevokeTokenParams{expired, active} {
+		err := testQueries.RevokeToken(context.Background(), arg)
+		require.NoError(t, err)
+	}
+
+	err := testQueries.DeleteExpiredRevokedTokens(context.Background())
+	require.NoError(t, err)
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       expired.ID,
+		Username: user.Username,
+		IssuedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       active.ID,
+		Username: user.Username,
+		IssuedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestIsTokenRevokedByLockedUser(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: time.Now(),
+	}
+
+	lockedUser, err := testQueries.LockUser(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// the tokens issued before the lock stay revoked after the unlock
+	_, err = testQueries.UnlockUser(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	arg.IssuedAt = lockedUser.TokensValidAfter.Add(time.Second)
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	user := createRandomUser(t)
+	cutoff := time.Now().Add(time.Minute)
+
+	err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		IssuedBefore: cutoff,
+		Username:     user.Username,
+	})
+	require.NoError(t, err)
+
+	// an older cutoff doesn't move the current one back
+	err = testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		IssuedBefore: cutoff.Add(-time.Hour),
+		Username:     user.Username,
+	})
+	require.NoError(t, err)
+
+	user2, err := testQueries.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.NotNil(t, user2.TokensValidAfter)
+	require.WithinDuration(t, cutoff, *user2.TokensValidAfter, time.Second)
+
+	arg := IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: cutoff.Add(-time.Second),
+	}
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	arg.IssuedAt = cutoff.Add(time.Second)
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
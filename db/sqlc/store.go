@@ -1,12 +1,28 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"time"
 )
 
 // Store provides all functions to execute db queries and transactions
 type Store interface {
 	Querier
+	ListTasksSorted(ctx context.Context, arg ListTasksParams, sorts []TaskSort) ([]Task, error)
+	CreateTaskTx(ctx context.Context, arg CreateTaskTxParams) (CreateTaskTxResult, error)
+	UpdateTaskTx(ctx context.Context, arg UpdateTaskTxParams) (UpdateTaskTxResult, error)
+	SetTaskTagsTx(ctx context.Context, arg SetTaskTagsTxParams) ([]Tag, error)
+	MoveTasksTx(ctx context.Context, arg MoveTasksParams) ([]Task, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmailTxResult, error)
+	DeleteUserTx(ctx context.Context, username string) error
+	ExportUserTx(ctx context.Context, username string) (ExportUserTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	EnrollTotpTx(ctx context.Context, arg EnrollTotpTxParams) (UserTotp, error)
+	UseMfaChallengeTx(ctx context.Context, arg UseMfaChallengeTxParams) (MfaChallenge, error)
 }
 
 // Store provides all functions to execute SQL queries and transactions
@@ -22,3 +38,77 @@ func NewStore(db *sql.DB) Store {
 		Queries: New(db),
 	}
 }
+
+// maxTxAttempts is how many times a transaction runs before giving up on
+// serialization failures and deadlocks
+const maxTxAttempts = 3
+
+// txRetryDelay is the base wait between two attempts of a transaction
+const txRetryDelay = 10 * time.Millisecond
+
+// execTx executes a function within a database transaction, retrying the whole
+// transaction when postgres aborts it because of a serialization failure or a
+// deadlock. fn may run more than once so it must not keep state between calls
+func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	return store.execTxOptions(ctx, nil, fn)
+}
+
+// execTxOptions is execTx with the given transaction options, a nil opts uses
+// the default isolation level of the database
+func (store *SQLStore) execTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(*Queries) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = store.runTx(ctx, opts, fn)
+		if !retryableTxError(err) || attempt == maxTxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+	return err
+}
+
+// runTx executes a function within a single database transaction
+func (store *SQLStore) runTx(ctx context.Context, opts *sql.TxOptions, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	err = fn(store.WithTx(tx))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryableTxError reports whether the transaction can succeed if it runs again
+func retryableTxError(err error) bool {
+	switch ErrorCode(err) {
+	case SerializationFailure, DeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// uniqueIDs removes the repeated ids keeping the original order
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
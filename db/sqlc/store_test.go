@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTaskTx(t *testing.T) {
+	user := createRandomUser(t)
+	tag1 := createRandomTag(t, user.Username)
+	tag2 := createRandomTag(t, user.Username)
+
+	arg := CreateTaskTxParams{
+		CreateTaskParams: CreateTaskParams{
+			Owner:    user.Username,
+			Title:    "Task with tags",
+			Priority: TaskPriorityHigh,
+		},
+		TagIDs: []int64{tag1.ID, tag2.ID, tag1.ID},
+	}
+
+	result, err := testQueries.CreateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, result.Task.ID)
+	require.Len(t, result.Tags, 2)
+
+	tags, err := testQueries.ListTaskTags(context.Background(), result.Task.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, result.Tags, tags)
+}
+
+func TestCreateTaskTxForeignTag(t *testing.T) {
+	user1 := createRandomUser(t)
+	user2 := createRandomUser(t)
+	tag := createRandomTag(t, user2.Username)
+
+	arg := CreateTaskTxParams{
+		CreateTaskParams: CreateTaskParams{
+			Owner:       user1.Username,
+			Title:       "Task with a foreign tag",
+			Description: user1.Username,
+			Priority:    TaskPriorityLow,
+		},
+		TagIDs: []int64{tag.ID},
+	}
+
+	result, err := testQueries.CreateTaskTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrTagNotFound)
+	require.Empty(t, result.Task)
+
+	// the task must not exist after the rollback
+	tasks, err := testQueries.ListTasks(context.Background(), ListTasksParams{
+		Owner: user1.Username,
+		Limit: 5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+}
+
+func TestSetTaskTagsTx(t *testing.T) {
+	task := createRandomTask(t, "Set task tags")
+	tag1 := createRandomTag(t, task.Owner)
+	tag2 := createRandomTag(t, task.Owner)
+
+	tags, err := testQueries.SetTaskTagsTx(context.Background(), SetTaskTagsTxParams{
+		TaskID: task.ID,
+		Owner:  task.Owner,
+		TagIDs: []int64{tag1.ID, tag2.ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	tags, err = testQueries.SetTaskTagsTx(context.Background(), SetTaskTagsTxParams{
+		TaskID: task.ID,
+		Owner:  task.Owner,
+		TagIDs: []int64{tag2.ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+
+	gotTags, err := testQueries.ListTaskTags(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Len(t, gotTags, 1)
+	require.Equal(t, tag2.ID, gotTags[0].ID)
+}
+
+func TestMoveTasksTx(t *testing.T) {
+	user := createRandomUser(t)
+	project := createRandomProject(t, user.Username)
+	task1 := createRandomTaskWithOwner(t, user.Username, "Move task")
+	task2 := createRandomTaskWithOwner(t, user.Username, "Move task")
+	foreignTask := createRandomTask(t, "Foreign task")
+
+	arg := MoveTasksParams{
+		ProjectID: &project.ID,
+		Owner:     user.Username,
+		Ids:       []int64{task1.ID, task2.ID, foreignTask.ID},
+	}
+
+	tasks, err := testQueries.MoveTasksTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrTaskNotFound)
+	require.Empty(t, tasks)
+
+	gotTask, err := testQueries.GetTask(context.Background(), task1.ID)
+	require.NoError(t, err)
+	require.Nil(t, gotTask.ProjectID)
+
+	arg.Ids = []int64{task1.ID, task2.ID}
+	tasks, err = testQueries.MoveTasksTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	for _, task := range tasks {
+		require.Equal(t, project.ID, *task.ProjectID)
+	}
+}
+
+func TestDeleteUserTx(t *testing.T) {
+	user := createRandomUser(t)
+	task := createRandomTaskWithOwner(t, user.Username, "Delete user")
+	tag := createRandomTag(t, user.Username)
+	project := createRandomProject(t, user.Username)
+	session := createRandomSession(t, user.Username)
+	reset, _ := createRandomPasswordReset(t, user.Username, time.Now().Add(time.Minute))
+	verifyEmail, verifyCode := createRandomVerifyEmail(t, user, time.Now().Add(time.Minute))
+
+	err := testQueries.AddTaskTag(context.Background(), AddTaskTagParams{
+		TaskID: task.ID,
+		TagID:  tag.ID,
+	})
+	require.NoError(t, err)
+
+	err = testQueries.DeleteUserTx(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetUser(context.Background(), user.Username)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetTask(context.Background(), task.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetTag(context.Background(), tag.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetProject(context.Background(), project.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetSession(context.Background(), session.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.UsePasswordReset(context.Background(), reset.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.UseVerifyEmail(context.Background(), UseVerifyEmailParams{
+		ID:       verifyEmail.ID,
+		CodeHash: util.HashToken(verifyCode),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestExportUserTx(t *testing.T) {
+	user := createRandomUser(t)
+	project := createRandomProject(t, user.Username)
+	tag := createRandomTag(t, user.Username)
+	task := createRandomTaskWithOwner(t, user.Username, "Export user")
+	session := createRandomSession(t, user.Username)
+
+	err := testQueries.AddTaskTag(context.Background(), AddTaskTagParams{
+		TaskID: task.ID,
+		TagID:  tag.ID,
+	})
+	require.NoError(t, err)
+
+	// the data of other users is not exported
+	other := createRandomUser(t)
+	createRandomTaskWithOwner(t, other.Username, "Other user")
+	createRandomTag(t, other.Username)
+
+	result, err := testQueries.ExportUserTx(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, result.User.Username)
+
+	require.Len(t, result.Projects, 1)
+	require.Equal(t, project.ID, result.Projects[0].ID)
+
+	require.Len(t, result.Tags, 1)
+	require.Equal(t, tag.ID, result.Tags[0].ID)
+
+	require.Len(t, result.Tasks, 1)
+	require.Equal(t, task.ID, result.Tasks[0].ID)
+
+	require.Equal(t, []TaskTag{{TaskID: task.ID, TagID: tag.ID}}, result.TaskTags)
+
+	require.Len(t, result.Sessions, 1)
+	require.Equal(t, session.ID, result.Sessions[0].ID)
+
+	_, err = testQueries.ExportUserTx(context.Background(), util.RandomUsername())
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestRetryableTxError(t *testing.T) {
+	require.True(t, retryableTxError(&pq.Error{Code: SerializationFailure}))
+	require.True(t, retryableTxError(&pq.Error{Code: DeadlockDetected}))
+	require.False(t, retryableTxError(&pq.Error{Code: UniqueViolation}))
+	require.False(t, retryableTxError(ErrUniqueViolation))
+	require.False(t, retryableTxError(errors.New("some error")))
+	require.False(t, retryableTxError(nil))
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTag(t *testing.T, owner string) Tag {
+	arg := CreateTagParams{
+		Owner: owner,
+		Name:  util.RandomPassword(12),
+	}
+
+	tag, err := testQueries.CreateTag(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, tag)
+
+	require.Equal(t, arg.Owner, tag.Owner)
+	require.Equal(t, arg.Name, tag.Name)
+	require.NotZero(t, tag.ID)
+	require.NotZero(t, tag.CreatedAt)
+
+	return tag
+}
+
+func TestCreateTag(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomTag(t, user.Username)
+}
+
+func TestCreateDuplicateTag(t *testing.T) {
+	user := createRandomUser(t)
+	tag1 := createRandomTag(t, user.Username)
+
+	tag2, err := testQueries.CreateTag(context.Background(), CreateTagParams{
+		Owner: tag1.Owner,
+		Name:  tag1.Name,
+	})
+	require.Error(t, err)
+	require.Empty(t, tag2)
+}
+
+func TestGetTag(t *testing.T) {
+	user := createRandomUser(t)
+	tag1 := createRandomTag(t, user.Username)
+
+	tag2, err := testQueries.GetTag(context.Background(), tag1.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, tag2)
+
+	require.Equal(t, tag1.ID, tag2.ID)
+	require.Equal(t, tag1.Owner, tag2.Owner)
+	require.Equal(t, tag1.Name, tag2.Name)
+	require.WithinDuration(t, tag1.CreatedAt, tag2.CreatedAt, time.Second)
+}
+
+func TestListTags(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 5; i++ {
+		createRandomTag(t, user.Username)
+	}
+
+	arg := ListTagsParams{
+		Owner:  user.Username,
+		Limit:  5,
+		Offset: 0,
+	}
+
+	tags, err := testQueries.ListTags(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, tags, 5)
+
+	for _, tag := range tags {
+		require.Equal(t, user.Username, tag.Owner)
+	}
+}
+
+func TestListTagsByIDs(t *testing.T) {
+	user1 := createRandomUser(t)
+	user2 := createRandomUser(t)
+	tag1 := createRandomTag(t, user1.Username)
+	tag2 := createRandomTag(t, user1.Username)
+	tag3 := createRandomTag(t, user2.Username)
+
+	arg := ListTagsByIDsParams{
+		Owner: user1.Username,
+		Ids:   []int64{tag1.ID, tag2.ID, tag3.ID},
+	}
+
+	tags, err := testQueries.ListTagsByIDs(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	for _, tag := range tags {
+		require.Equal(t, user1.Username, tag.Owner)
+	}
+}
+
+func TestTaskTags(t *testing.T) {
+	task := createRandomTask(t, "Task tags")
+	tag1 := createRandomTag(t, task.Owner)
+	tag2 := createRandomTag(t, task.Owner)
+
+	for _, tag := range []Tag{tag1, tag2, tag1} {
+		err := testQueries.AddTaskTag(context.Background(), AddTaskTagParams{
+			TaskID: task.ID,
+			TagID:  tag.ID,
+		})
+		require.NoError(t, err)
+	}
+
+	tags, err := testQueries.ListTaskTags(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	arg := ListTasksParams{
+		Owner:        task.Owner,
+		Tags:         []string{tag1.Name, tag2.Name},
+		MatchAllTags: true,
+		Limit:        5,
+		Offset:       0,
+	}
+
+	tasks, err := testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, task.ID, tasks[0].ID)
+
+	err = testQueries.DeleteTaskTags(context.Background(), task.ID)
+	require.NoError(t, err)
+
+	tags, err = testQueries.ListTaskTags(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+
+	tasks, err = testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+}
+
+func TestDeleteTag(t *testing.T) {
+	user := createRandomUser(t)
+	tag1 := createRandomTag(t, user.Username)
+
+	err := testQueries.DeleteTag(context.Background(), tag1.ID)
+	require.NoError(t, err)
+
+	tag2, err := testQueries.GetTag(context.Background(), tag1.ID)
+	require.Error(t, err)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.Empty(t, tag2)
+}
@@ -7,22 +7,57 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const completeSubtasks = `-- name: CompleteSubtasks :exec
+WITH RECURSIVE descendants AS (
+  SELECT id FROM tasks
+  WHERE parent_id = $1::bigint
+  UNION
+  SELECT tasks.id FROM tasks
+  JOIN descendants ON tasks.parent_id = descendants.id
 )
+UPDATE tasks
+SET done = TRUE
+WHERE id IN (SELECT id FROM descendants)
+`
+
+func (q *Queries) CompleteSubtasks(ctx context.Context, parentID int64) error {
+	_, err := q.db.ExecContext(ctx, completeSubtasks, parentID)
+	return err
+}
 
 const createTask = `-- name: CreateTask :one
-INSERT INTO tasks (owner,title,description)
-VALUES ($1,$2,$3)
-RETURNING id, owner, title, description, done, created_at
+INSERT INTO tasks (owner,title,description,due_at,priority,project_id,parent_id,recurrence)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+RETURNING id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id
 `
 
 type CreateTaskParams struct {
-	Owner       string `json:"owner"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Owner       string       `json:"owner"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	DueAt       *time.Time   `json:"due_at"`
+	Priority    TaskPriority `json:"priority"`
+	ProjectID   *int64       `json:"project_id"`
+	ParentID    *int64       `json:"parent_id"`
+	Recurrence  string       `json:"recurrence"`
 }
 
 func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error) {
-	row := q.db.QueryRowContext(ctx, createTask, arg.Owner, arg.Title, arg.Description)
+	row := q.db.QueryRowContext(ctx, createTask,
+		arg.Owner,
+		arg.Title,
+		arg.Description,
+		arg.DueAt,
+		arg.Priority,
+		arg.ProjectID,
+		arg.ParentID,
+		arg.Recurrence,
+	)
 	var i Task
 	err := row.Scan(
 		&i.ID,
@@ -31,6 +66,12 @@ func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, e
 		&i.Description,
 		&i.Done,
 		&i.CreatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ProjectID,
+		&i.ParentID,
+		&i.Recurrence,
+		&i.NextTaskID,
 	)
 	return i, err
 }
@@ -45,8 +86,18 @@ func (q *Queries) DeleteTask(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteTasksByOwner = `-- name: DeleteTasksByOwner :exec
+DELETE FROM tasks
+WHERE owner = $1
+`
+
+func (q *Queries) DeleteTasksByOwner(ctx context.Context, owner string) error {
+	_, err := q.db.ExecContext(ctx, deleteTasksByOwner, owner)
+	return err
+}
+
 const getTask = `-- name: GetTask :one
-SELECT id, owner, title, description, done, created_at FROM tasks
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
 WHERE id = $1 
 LIMIT 1
 `
@@ -61,26 +112,353 @@ func (q *Queries) GetTask(ctx context.Context, id int64) (Task, error) {
 		&i.Description,
 		&i.Done,
 		&i.CreatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ProjectID,
+		&i.ParentID,
+		&i.Recurrence,
+		&i.NextTaskID,
 	)
 	return i, err
 }
 
+const getTaskForUpdate = `-- name: GetTaskForUpdate :one
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTaskForUpdate(ctx context.Context, id int64) (Task, error) {
+	row := q.db.QueryRowContext(ctx, getTaskForUpdate, id)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Title,
+		&i.Description,
+		&i.Done,
+		&i.CreatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ProjectID,
+		&i.ParentID,
+		&i.Recurrence,
+		&i.NextTaskID,
+	)
+	return i, err
+}
+
+const getTaskProgress = `-- name: GetTaskProgress :one
+SELECT count(*) AS total,
+count(*) FILTER (WHERE done) AS done
+FROM tasks
+WHERE parent_id = $1::bigint
+`
+
+type GetTaskProgressRow struct {
+	Total int64 `json:"total"`
+	Done  int64 `json:"done"`
+}
+
+func (q *Queries) GetTaskProgress(ctx context.Context, parentID int64) (GetTaskProgressRow, error) {
+	row := q.db.QueryRowContext(ctx, getTaskProgress, parentID)
+	var i GetTaskProgressRow
+	err := row.Scan(&i.Total, &i.Done)
+	return i, err
+}
+
+const isTaskDescendant = `-- name: IsTaskDescendant :one
+WITH RECURSIVE descendants AS (
+  SELECT id FROM tasks
+  WHERE parent_id = $1::bigint
+  UNION
+  SELECT tasks.id FROM tasks
+  JOIN descendants ON tasks.parent_id = descendants.id
+)
+SELECT EXISTS (
+  SELECT 1 FROM descendants
+  WHERE id = $2::bigint
+)
+`
+
+type IsTaskDescendantParams struct {
+	AncestorID int64 `json:"ancestor_id"`
+	TaskID     int64 `json:"task_id"`
+}
+
+func (q *Queries) IsTaskDescendant(ctx context.Context, arg IsTaskDescendantParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isTaskDescendant, arg.AncestorID, arg.TaskID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listAllTasks = `-- name: ListAllTasks :many
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
+WHERE $1::varchar = ''
+OR owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListAllTasksParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListAllTasks(ctx context.Context, arg ListAllTasksParams) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, listAllTasks, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Task{}
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Title,
+			&i.Description,
+			&i.Done,
+			&i.CreatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ProjectID,
+			&i.ParentID,
+			&i.Recurrence,
+			&i.NextTaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubtasks = `-- name: ListSubtasks :many
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
+WHERE parent_id = $1::bigint
+ORDER BY id
+`
+
+func (q *Queries) ListSubtasks(ctx context.Context, parentID int64) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, listSubtasks, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Task{}
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Title,
+			&i.Description,
+			&i.Done,
+			&i.CreatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ProjectID,
+			&i.ParentID,
+			&i.Recurrence,
+			&i.NextTaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaskProgress = `-- name: ListTaskProgress :many
+SELECT parent_id::bigint AS parent_id,
+count(*) AS total,
+count(*) FILTER (WHERE done) AS done
+FROM tasks
+WHERE parent_id = ANY($1::bigint[])
+GROUP BY parent_id
+`
+
+type ListTaskProgressRow struct {
+	ParentID int64 `json:"parent_id"`
+	Total    int64 `json:"total"`
+	Done     int64 `json:"done"`
+}
+
+func (q *Queries) ListTaskProgress(ctx context.Context, parentIds []int64) ([]ListTaskProgressRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTaskProgress, pq.Array(parentIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTaskProgressRow{}
+	for rows.Next() {
+		var i ListTaskProgressRow
+		if err := rows.Scan(&i.ParentID, &i.Total, &i.Done); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTasks = `-- name: ListTasks :many
-SELECT id, owner, title, description, done, created_at FROM tasks
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
 WHERE owner = $1
+AND ($2::timestamptz IS NULL OR due_at < $2)
+AND ($3::timestamptz IS NULL OR due_at > $3)
+AND (NOT $4::boolean OR (due_at < now() AND NOT done))
+AND (coalesce(cardinality($5::varchar[]), 0) = 0 OR (
+  SELECT count(DISTINCT tags.name) FROM task_tags
+  JOIN tags ON tags.id = task_tags.tag_id
+  WHERE task_tags.task_id = tasks.id
+  AND tags.name = ANY($5::varchar[])
+) >= CASE WHEN $6::boolean THEN cardinality($5::varchar[]) ELSE 1 END)
+AND ($7::bigint IS NULL OR project_id = $7)
 ORDER BY id
-OFFSET $2
-LIMIT $3
+OFFSET $8
+LIMIT $9
 `
 
 type ListTasksParams struct {
-	Owner  string `json:"owner"`
-	Offset int32  `json:"offset"`
-	Limit  int32  `json:"limit"`
+	Owner        string     `json:"owner"`
+	DueBefore    *time.Time `json:"due_before"`
+	DueAfter     *time.Time `json:"due_after"`
+	Overdue      bool       `json:"overdue"`
+	Tags         []string   `json:"tags"`
+	MatchAllTags bool       `json:"match_all_tags"`
+	ProjectID    *int64     `json:"project_id"`
+	Offset       int32      `json:"offset"`
+	Limit        int32      `json:"limit"`
 }
 
 func (q *Queries) ListTasks(ctx context.Context, arg ListTasksParams) ([]Task, error) {
-	rows, err := q.db.QueryContext(ctx, listTasks, arg.Owner, arg.Offset, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, listTasks,
+		arg.Owner,
+		arg.DueBefore,
+		arg.DueAfter,
+		arg.Overdue,
+		pq.Array(arg.Tags),
+		arg.MatchAllTags,
+		arg.ProjectID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Task{}
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Title,
+			&i.Description,
+			&i.Done,
+			&i.CreatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ProjectID,
+			&i.ParentID,
+			&i.Recurrence,
+			&i.NextTaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTasksByOwner = `-- name: ListTasksByOwner :many
+SELECT id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id FROM tasks
+WHERE owner = $1
+ORDER BY id
+`
+
+func (q *Queries) ListTasksByOwner(ctx context.Context, owner string) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, listTasksByOwner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Task{}
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Title,
+			&i.Description,
+			&i.Done,
+			&i.CreatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ProjectID,
+			&i.ParentID,
+			&i.Recurrence,
+			&i.NextTaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveTasks = `-- name: MoveTasks :many
+UPDATE tasks
+SET project_id = $1
+WHERE owner = $2
+AND id = ANY($3::bigint[])
+RETURNING id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id
+`
+
+type MoveTasksParams struct {
+	ProjectID *int64  `json:"project_id"`
+	Owner     string  `json:"owner"`
+	Ids       []int64 `json:"ids"`
+}
+
+func (q *Queries) MoveTasks(ctx context.Context, arg MoveTasksParams) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, moveTasks, arg.ProjectID, arg.Owner, pq.Array(arg.Ids))
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +473,12 @@ func (q *Queries) ListTasks(ctx context.Context, arg ListTasksParams) ([]Task, e
 			&i.Description,
 			&i.Done,
 			&i.CreatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ProjectID,
+			&i.ParentID,
+			&i.Recurrence,
+			&i.NextTaskID,
 		); err != nil {
 			return nil, err
 		}
@@ -109,20 +493,62 @@ func (q *Queries) ListTasks(ctx context.Context, arg ListTasksParams) ([]Task, e
 	return items, nil
 }
 
+const setTaskNextTask = `-- name: SetTaskNextTask :one
+UPDATE tasks
+SET next_task_id = $2
+WHERE id = $1
+RETURNING id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id
+`
+
+type SetTaskNextTaskParams struct {
+	ID         int64  `json:"id"`
+	NextTaskID *int64 `json:"next_task_id"`
+}
+
+func (q *Queries) SetTaskNextTask(ctx context.Context, arg SetTaskNextTaskParams) (Task, error) {
+	row := q.db.QueryRowContext(ctx, setTaskNextTask, arg.ID, arg.NextTaskID)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Title,
+		&i.Description,
+		&i.Done,
+		&i.CreatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ProjectID,
+		&i.ParentID,
+		&i.Recurrence,
+		&i.NextTaskID,
+	)
+	return i, err
+}
+
 const updateTask = `-- name: UpdateTask :one
 UPDATE tasks
 SET title = $2,
 description = $3,
-done = $4
+done = $4,
+due_at = $5,
+priority = $6,
+project_id = $7,
+parent_id = $8,
+recurrence = $9
 WHERE id = $1
-RETURNING id, owner, title, description, done, created_at
+RETURNING id, owner, title, description, done, created_at, due_at, priority, project_id, parent_id, recurrence, next_task_id
 `
 
 type UpdateTaskParams struct {
-	ID          int64  `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	ID          int64        `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Done        bool         `json:"done"`
+	DueAt       *time.Time   `json:"due_at"`
+	Priority    TaskPriority `json:"priority"`
+	ProjectID   *int64       `json:"project_id"`
+	ParentID    *int64       `json:"parent_id"`
+	Recurrence  string       `json:"recurrence"`
 }
 
 func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, error) {
@@ -131,6 +557,11 @@ func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, e
 		arg.Title,
 		arg.Description,
 		arg.Done,
+		arg.DueAt,
+		arg.Priority,
+		arg.ProjectID,
+		arg.ParentID,
+		arg.Recurrence,
 	)
 	var i Task
 	err := row.Scan(
@@ -140,6 +571,12 @@ func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, e
 		&i.Description,
 		&i.Done,
 		&i.CreatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ProjectID,
+		&i.ParentID,
+		&i.Recurrence,
+		&i.NextTaskID,
 	)
 	return i, err
 }
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TaskSort orders a task listing by a single field
+type TaskSort struct {
+	Field string
+	Desc  bool
+}
+
+// taskSortColumns is the whitelist of fields tasks can be sorted by
+var taskSortColumns = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"done":       "done",
+	"priority":   "priority",
+	"due_at":     "due_at",
+	"created_at": "created_at",
+}
+
+// listTasksOrderBy is the ORDER BY clause of the ListTasks query
+const listTasksOrderBy = "ORDER BY id"
+
+// ParseTaskSort parses a comma separated list of fields like "-priority,due_at",
+// where a leading '-' means descending order
+func ParseTaskSort(sort string) ([]TaskSort, error) {
+	if strings.TrimSpace(sort) == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(sort, ",")
+	sorts := make([]TaskSort, 0, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+
+		if _, ok := taskSortColumns[field]; !ok {
+			return nil, fmt.Errorf("cannot sort tasks by '%s'", field)
+		}
+
+		sorts = append(sorts, TaskSort{
+			Field: field,
+			Desc:  desc,
+		})
+	}
+
+	return sorts, nil
+}
+
+// taskOrderBy translates the sort fields into an ORDER BY clause, always
+// ending with the id so that pagination stays stable
+func taskOrderBy(sorts []TaskSort) (string, error) {
+	clauses := make([]string, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column, ok := taskSortColumns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("cannot sort tasks by '%s'", sort.Field)
+		}
+
+		direction := "ASC"
+		if sort.Desc {
+			direction = "DESC"
+		}
+
+		clauses = append(clauses, fmt.Sprintf("%s %s NULLS LAST", column, direction))
+	}
+	clauses = append(clauses, "id")
+
+	return "ORDER BY " + strings.Join(clauses, ", "), nil
+}
+
+// ListTasksSorted runs the ListTasks query ordering the tasks by the given fields
+func (q *Queries) ListTasksSorted(ctx context.Context, arg ListTasksParams, sorts []TaskSort) ([]Task, error) {
+	orderBy, err := taskOrderBy(sorts)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(orderedDBTX{DBTX: q.db, orderBy: orderBy}).ListTasks(ctx, arg)
+}
+
+// orderedDBTX replaces the ORDER BY clause of the queries it runs. It fails
+// when the query doesn't have exactly one listTasksOrderBy, so a change in the
+// generated query can't make the sort silently ignored
+type orderedDBTX struct {
+	DBTX
+	orderBy string
+}
+
+func (db orderedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if strings.Count(query, listTasksOrderBy) != 1 {
+		return nil, fmt.Errorf("cannot sort tasks: the query doesn't have a single '%s'", listTasksOrderBy)
+	}
+
+	query = strings.Replace(query, listTasksOrderBy, db.orderBy, 1)
+	return db.DBTX.QueryContext(ctx, query, args...)
+}
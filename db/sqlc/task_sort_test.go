@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTaskSort(t *testing.T) {
+	type test struct {
+		name  string
+		sort  string
+		sorts []TaskSort
+		isErr bool
+	}
+
+	tests := []test{
+		{
+			name:  "Empty",
+			sort:  "",
+			sorts: nil,
+			isErr: false,
+		},
+		{
+			name: "Several fields",
+			sort: "-priority, due_at,created_at",
+			sorts: []TaskSort{
+				{Field: "priority", Desc: true},
+				{Field: "due_at", Desc: false},
+				{Field: "created_at", Desc: false},
+			},
+			isErr: false,
+		},
+		{
+			name:  "Unknown field",
+			sort:  "owner",
+			isErr: true,
+		},
+		{
+			name:  "Injection",
+			sort:  "id;DROP TABLE tasks",
+			isErr: true,
+		},
+	}
+
+	for _, x := range tests {
+		t.Run(x.name, func(t *testing.T) {
+			sorts, err := ParseTaskSort(x.sort)
+			require.Equal(t, x.isErr, err != nil)
+			require.Equal(t, x.sorts, sorts)
+		})
+	}
+}
+
+func TestTaskOrderBy(t *testing.T) {
+	orderBy, err := taskOrderBy(nil)
+	require.NoError(t, err)
+	require.Equal(t, "ORDER BY id", orderBy)
+
+	orderBy, err = taskOrderBy([]TaskSort{
+		{Field: "priority", Desc: true},
+		{Field: "due_at", Desc: false},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ORDER BY priority DESC NULLS LAST, due_at ASC NULLS LAST, id", orderBy)
+
+	orderBy, err = taskOrderBy([]TaskSort{{Field: "owner"}})
+	require.Error(t, err)
+	require.Empty(t, orderBy)
+}
+
+func TestListTasksOrderBy(t *testing.T) {
+	// the sort replaces this clause of the generated query, it must still be there
+	require.Equal(t, 1, strings.Count(listTasks, listTasksOrderBy))
+
+	rows, err := orderedDBTX{orderBy: "ORDER BY title, id"}.QueryContext(context.Background(), "SELECT id FROM tasks")
+	require.Error(t, err)
+	require.Nil(t, rows)
+}
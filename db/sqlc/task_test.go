@@ -12,10 +12,17 @@ import (
 
 func createRandomTask(t *testing.T, name string) Task {
 	user := createRandomUser(t)
+	return createRandomTaskWithOwner(t, user.Username, name)
+}
+
+func createRandomTaskWithOwner(t *testing.T, owner string, name string) Task {
+	dueAt := time.Now().Add(time.Duration(util.RandomInt(-72, 72)) * time.Hour)
 	arg := CreateTaskParams{
-		Owner:       user.Username,
+		Owner:       owner,
 		Title:       util.RandomTitle(),
 		Description: name,
+		DueAt:       &dueAt,
+		Priority:    randomPriority(),
 	}
 
 	task, err := testQueries.CreateTask(context.Background(), arg)
@@ -69,6 +76,108 @@ func TestListTasks(t *testing.T) {
 	}
 }
 
+func TestListAllTasks(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomTaskWithOwner(t, user.Username, "List all tasks")
+	}
+
+	tasks, err := testQueries.ListAllTasks(context.Background(), ListAllTasksParams{
+		Owner:  user.Username,
+		Limit:  5,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+	for _, task := range tasks {
+		require.Equal(t, user.Username, task.Owner)
+	}
+
+	tasks, err = testQueries.ListAllTasks(context.Background(), ListAllTasksParams{
+		Limit:  5,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, tasks, 5)
+}
+
+func TestListTasksByDueDate(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 10; i++ {
+		createRandomTaskWithOwner(t, user.Username, "List task by due date")
+	}
+
+	now := time.Now()
+	dueAfter := now.Add(-24 * time.Hour)
+	arg := ListTasksParams{
+		Owner:     user.Username,
+		DueBefore: &now,
+		DueAfter:  &dueAfter,
+		Limit:     10,
+		Offset:    0,
+	}
+
+	tasks, err := testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+
+	for _, task := range tasks {
+		require.NotNil(t, task.DueAt)
+		require.True(t, task.DueAt.Before(now))
+		require.True(t, task.DueAt.After(dueAfter))
+	}
+
+	arg = ListTasksParams{
+		Owner:   user.Username,
+		Overdue: true,
+		Limit:   10,
+		Offset:  0,
+	}
+
+	tasks, err = testQueries.ListTasks(context.Background(), arg)
+	require.NoError(t, err)
+
+	for _, task := range tasks {
+		require.False(t, task.Done)
+		require.True(t, task.DueAt.Before(time.Now()))
+	}
+}
+
+func TestListTasksSorted(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 10; i++ {
+		createRandomTaskWithOwner(t, user.Username, "List task sorted")
+	}
+
+	arg := ListTasksParams{
+		Owner:  user.Username,
+		Limit:  10,
+		Offset: 0,
+	}
+
+	sorts := []TaskSort{
+		{Field: "priority", Desc: true},
+		{Field: "due_at", Desc: false},
+	}
+
+	tasks, err := testQueries.ListTasksSorted(context.Background(), arg, sorts)
+	require.NoError(t, err)
+	require.Len(t, tasks, 10)
+
+	priorities := map[TaskPriority]int{
+		TaskPriorityLow:    0,
+		TaskPriorityMedium: 1,
+		TaskPriorityHigh:   2,
+		TaskPriorityUrgent: 3,
+	}
+	for i := 1; i < len(tasks); i++ {
+		prev, curr := tasks[i-1], tasks[i]
+		require.GreaterOrEqual(t, priorities[prev.Priority], priorities[curr.Priority])
+		if prev.Priority == curr.Priority {
+			require.False(t, curr.DueAt.Before(*prev.DueAt))
+		}
+	}
+}
+
 func TestUpdateTask(t *testing.T) {
 	task1 := createRandomTask(t, "Update Task")
 	arg := UpdateTaskParams{
@@ -76,6 +185,8 @@ func TestUpdateTask(t *testing.T) {
 		Title:       util.RandomTitle() + ":)",
 		Description: "Updated task",
 		Done:        true,
+		DueAt:       nil,
+		Priority:    TaskPriorityUrgent,
 	}
 
 	task2, err := testQueries.UpdateTask(context.Background(), arg)
@@ -90,6 +201,204 @@ func TestUpdateTask(t *testing.T) {
 	require.WithinDuration(t, task1.CreatedAt, task2.CreatedAt, time.Second)
 }
 
+func createRandomSubtask(t *testing.T, parent Task) Task {
+	arg := CreateTaskParams{
+		Owner:       parent.Owner,
+		Title:       util.RandomTitle(),
+		Description: "Subtask",
+		Priority:    randomPriority(),
+		ParentID:    &parent.ID,
+	}
+
+	task, err := testQueries.CreateTask(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotNil(t, task.ParentID)
+	require.Equal(t, parent.ID, *task.ParentID)
+
+	return task
+}
+
+func TestSubtasks(t *testing.T) {
+	parent := createRandomTask(t, "Parent task")
+
+	children := make([]Task, 3)
+	for i := range children {
+		children[i] = createRandomSubtask(t, parent)
+	}
+	grandchild := createRandomSubtask(t, children[0])
+
+	subtasks, err := testQueries.ListSubtasks(context.Background(), parent.ID)
+	require.NoError(t, err)
+	require.Len(t, subtasks, len(children))
+
+	progress, err := testQueries.GetTaskProgress(context.Background(), parent.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(children)), progress.Total)
+	require.Zero(t, progress.Done)
+
+	arg := UpdateTaskTxParams{
+		UpdateTaskParams: UpdateTaskParams{
+			ID:          parent.ID,
+			Title:       parent.Title,
+			Description: parent.Description,
+			Done:        true,
+			DueAt:       parent.DueAt,
+			Priority:    parent.Priority,
+		},
+		CascadeDone: true,
+	}
+
+	result, err := testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.Task.Done)
+	require.Nil(t, result.NextTask)
+
+	progress, err = testQueries.GetTaskProgress(context.Background(), parent.ID)
+	require.NoError(t, err)
+	require.Equal(t, progress.Total, progress.Done)
+
+	updatedGrandchild, err := testQueries.GetTask(context.Background(), grandchild.ID)
+	require.NoError(t, err)
+	require.True(t, updatedGrandchild.Done)
+}
+
+func TestListTaskProgress(t *testing.T) {
+	parent := createRandomTask(t, "Parent task")
+	children := make([]Task, 2)
+	for i := range children {
+		children[i] = createRandomSubtask(t, parent)
+	}
+	createRandomSubtask(t, children[0])
+	leaf := createRandomTask(t, "Leaf task")
+
+	ids := []int64{parent.ID, children[0].ID, children[1].ID, leaf.ID}
+	rows, err := testQueries.ListTaskProgress(context.Background(), ids)
+	require.NoError(t, err)
+
+	// tasks without subtasks have no row
+	require.ElementsMatch(t, []ListTaskProgressRow{
+		{ParentID: parent.ID, Total: int64(len(children)), Done: 0},
+		{ParentID: children[0].ID, Total: 1, Done: 0},
+	}, rows)
+}
+
+func TestUpdateTaskCycle(t *testing.T) {
+	parent := createRandomTask(t, "Parent task")
+	child := createRandomSubtask(t, parent)
+	grandchild := createRandomSubtask(t, child)
+
+	for _, parentID := range []int64{parent.ID, grandchild.ID} {
+		task, err := testQueries.UpdateTask(context.Background(), UpdateTaskParams{
+			ID:       parent.ID,
+			Title:    parent.Title,
+			Priority: parent.Priority,
+			ParentID: &parentID,
+		})
+		require.ErrorIs(t, err, ErrTaskCycle)
+		require.Empty(t, task)
+	}
+}
+
+func TestUpdateTaskConcurrentCycle(t *testing.T) {
+	n := 5
+	errs := make(chan error, 2*n)
+
+	for i := 0; i < n; i++ {
+		task1 := createRandomTask(t, "Concurrent cycle")
+		task2 := createRandomTaskWithOwner(t, task1.Owner, "Concurrent cycle")
+
+		// each task is moved under the other at the same time, only one can win
+		for _, pair := range [][2]Task{{task1, task2}, {task2, task1}} {
+			task, parent := pair[0], pair[1]
+			go func() {
+				_, err := testQueries.UpdateTask(context.Background(), UpdateTaskParams{
+					ID:       task.ID,
+					Title:    task.Title,
+					Priority: task.Priority,
+					ParentID: &parent.ID,
+				})
+				errs <- err
+			}()
+		}
+	}
+
+	cycles := 0
+	for i := 0; i < 2*n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrTaskCycle)
+			cycles++
+		}
+	}
+	require.Equal(t, n, cycles)
+}
+
+func TestRecurringTask(t *testing.T) {
+	task := createRandomTask(t, "Recurring task")
+	tag := createRandomTag(t, task.Owner)
+
+	err := testQueries.AddTaskTag(context.Background(), AddTaskTagParams{
+		TaskID: task.ID,
+		TagID:  tag.ID,
+	})
+	require.NoError(t, err)
+
+	arg := UpdateTaskTxParams{
+		UpdateTaskParams: UpdateTaskParams{
+			ID:          task.ID,
+			Title:       task.Title,
+			Description: task.Description,
+			Done:        true,
+			DueAt:       task.DueAt,
+			Priority:    task.Priority,
+			Recurrence:  "FREQ=DAILY;INTERVAL=2;COUNT=2",
+		},
+	}
+
+	result, err := testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.Task.Done)
+	require.NotNil(t, result.NextTask)
+
+	next := result.NextTask
+	require.Equal(t, &next.ID, result.Task.NextTaskID)
+	require.NotEqual(t, task.ID, next.ID)
+	require.Equal(t, task.Owner, next.Owner)
+	require.Equal(t, task.Title, next.Title)
+	require.False(t, next.Done)
+	require.Equal(t, "FREQ=DAILY;INTERVAL=2;COUNT=1", next.Recurrence)
+	require.WithinDuration(t, task.DueAt.AddDate(0, 0, 2), *next.DueAt, time.Second)
+
+	tags, err := testQueries.ListTaskTags(context.Background(), next.ID)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	require.Equal(t, tag.ID, tags[0].ID)
+
+	// updating a task that is already done doesn't create another occurrence
+	result, err = testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Nil(t, result.NextTask)
+
+	// nor does undoing it and doing it again
+	arg.Done = false
+	result, err = testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Nil(t, result.NextTask)
+
+	arg.Done = true
+	result, err = testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Nil(t, result.NextTask)
+	require.Equal(t, &next.ID, result.Task.NextTaskID)
+
+	// the last occurrence of the series doesn't create another one
+	arg.ID = next.ID
+	arg.Recurrence = next.Recurrence
+	result, err = testQueries.UpdateTaskTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Nil(t, result.NextTask)
+}
+
 func TestDeleteTask(t *testing.T) {
 	task1 := createRandomTask(t, "Delete task")
 	err := testQueries.DeleteTask(context.Background(), task1.ID)
@@ -100,3 +409,13 @@ func TestDeleteTask(t *testing.T) {
 	require.EqualError(t, err, sql.ErrNoRows.Error())
 	require.Empty(t, task2)
 }
+
+func randomPriority() TaskPriority {
+	priorities := []TaskPriority{
+		TaskPriorityLow,
+		TaskPriorityMedium,
+		TaskPriorityHigh,
+		TaskPriorityUrgent,
+	}
+	return priorities[util.RandomInt(0, len(priorities))]
+}
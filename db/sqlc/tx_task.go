@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/recurrence"
+)
+
+var (
+	ErrTaskCycle    = errors.New("a task can't be a subtask of itself or of its own subtasks")
+	ErrTaskNotFound = errors.New("some tasks don't exist or don't belong to the user")
+	ErrTagNotFound  = errors.New("some tags don't exist or don't belong to the user")
+)
+
+// CreateTaskTxParams contains the input parameters of the create task transaction
+type CreateTaskTxParams struct {
+	CreateTaskParams
+	TagIDs []int64 `json:"tag_ids"`
+}
+
+// CreateTaskTxResult is the result of the create task transaction
+type CreateTaskTxResult struct {
+	Task Task  `json:"task"`
+	Tags []Tag `json:"tags"`
+}
+
+// CreateTaskTx creates a task and attaches the given tags of its owner to it
+func (store *SQLStore) CreateTaskTx(ctx context.Context, arg CreateTaskTxParams) (CreateTaskTxResult, error) {
+	var result CreateTaskTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Tags, err = ownedTags(ctx, q, arg.Owner, arg.TagIDs)
+		if err != nil {
+			return err
+		}
+
+		result.Task, err = q.CreateTask(ctx, arg.CreateTaskParams)
+		if err != nil {
+			return err
+		}
+
+		return addTaskTags(ctx, q, result.Task.ID, result.Tags)
+	})
+
+	return result, err
+}
+
+// SetTaskTagsTxParams contains the input parameters of the set task tags transaction
+type SetTaskTagsTxParams struct {
+	TaskID int64   `json:"task_id"`
+	Owner  string  `json:"owner"`
+	TagIDs []int64 `json:"tag_ids"`
+}
+
+// SetTaskTagsTx replaces the tags of a task with the given tags of its owner
+func (store *SQLStore) SetTaskTagsTx(ctx context.Context, arg SetTaskTagsTxParams) ([]Tag, error) {
+	var tags []Tag
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		tags, err = ownedTags(ctx, q, arg.Owner, arg.TagIDs)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteTaskTags(ctx, arg.TaskID)
+		if err != nil {
+			return err
+		}
+
+		return addTaskTags(ctx, q, arg.TaskID, tags)
+	})
+
+	return tags, err
+}
+
+// MoveTasksTx moves tasks of an owner to a project, or out of any project when
+// ProjectID is nil. Either every task is moved or none of them
+func (store *SQLStore) MoveTasksTx(ctx context.Context, arg MoveTasksParams) ([]Task, error) {
+	var tasks []Task
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		arg.Ids = uniqueIDs(arg.Ids)
+		tasks, err = q.MoveTasks(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) != len(arg.Ids) {
+			return ErrTaskNotFound
+		}
+
+		return nil
+	})
+
+	return tasks, err
+}
+
+// UpdateTaskTxParams contains the input parameters of the update task transaction
+type UpdateTaskTxParams struct {
+	UpdateTaskParams
+	CascadeDone bool `json:"cascade_done"`
+}
+
+// UpdateTaskTxResult is the result of the update task transaction
+type UpdateTaskTxResult struct {
+	Task     Task  `json:"task"`
+	NextTask *Task `json:"next_task"`
+}
+
+// UpdateTaskTx updates a task making sure its parent doesn't create a cycle,
+// marks all its subtasks as done when CascadeDone is set and creates the next
+// occurrence when a recurring task gets done for the first time
+func (store *SQLStore) UpdateTaskTx(ctx context.Context, arg UpdateTaskTxParams) (UpdateTaskTxResult, error) {
+	var result UpdateTaskTxResult
+
+	// the cycle check reads the subtasks of the task, which a concurrent update
+	// could change by moving the new parent under it. Serializable makes postgres
+	// abort one of the two updates and execTx runs it again, now seeing the cycle
+	var opts *sql.TxOptions
+	if arg.ParentID != nil {
+		opts = &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+
+	err := store.execTxOptions(ctx, opts, func(q *Queries) error {
+		result = UpdateTaskTxResult{}
+
+		current, err := q.GetTaskForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		if arg.ParentID != nil {
+			if *arg.ParentID == arg.ID {
+				return ErrTaskCycle
+			}
+
+			cycle, err := q.IsTaskDescendant(ctx, IsTaskDescendantParams{
+				AncestorID: arg.ID,
+				TaskID:     *arg.ParentID,
+			})
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return ErrTaskCycle
+			}
+		}
+
+		result.Task, err = q.UpdateTask(ctx, arg.UpdateTaskParams)
+		if err != nil {
+			return err
+		}
+
+		if arg.CascadeDone && result.Task.Done {
+			err = q.CompleteSubtasks(ctx, result.Task.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// a task that is undone and done again already has its next occurrence
+		if current.Done || !result.Task.Done || result.Task.Recurrence == "" || result.Task.NextTaskID != nil {
+			return nil
+		}
+
+		result.NextTask, err = createNextOccurrence(ctx, q, result.Task)
+		if err != nil || result.NextTask == nil {
+			return err
+		}
+
+		result.Task, err = q.SetTaskNextTask(ctx, SetTaskNextTaskParams{
+			ID:         result.Task.ID,
+			NextTaskID: &result.NextTask.ID,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// UpdateTask updates a task, rejecting parents that would create a cycle
+func (store *SQLStore) UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, error) {
+	result, err := store.UpdateTaskTx(ctx, UpdateTaskTxParams{UpdateTaskParams: arg})
+	return result.Task, err
+}
+
+// createNextOccurrence copies a recurring task and its tags with the due date
+// of the next occurrence, it returns nil when the recurrence is over
+func createNextOccurrence(ctx context.Context, q *Queries, task Task) (*Task, error) {
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now()
+	if task.DueAt != nil {
+		from = *task.DueAt
+	}
+
+	dueAt, ok := rule.Next(from)
+	if !ok {
+		return nil, nil
+	}
+
+	next, err := q.CreateTask(ctx, CreateTaskParams{
+		Owner:       task.Owner,
+		Title:       task.Title,
+		Description: task.Description,
+		DueAt:       &dueAt,
+		Priority:    task.Priority,
+		ProjectID:   task.ProjectID,
+		ParentID:    task.ParentID,
+		Recurrence:  rule.Following().String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := q.ListTaskTags(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addTaskTags(ctx, q, next.ID, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// ownedTags gets the tags with the given ids, failing if any of them doesn't
+// exist or belongs to someone else
+func ownedTags(ctx context.Context, q *Queries, owner string, ids []int64) ([]Tag, error) {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return []Tag{}, nil
+	}
+
+	tags, err := q.ListTagsByIDs(ctx, ListTagsByIDsParams{
+		Owner: owner,
+		Ids:   ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) != len(ids) {
+		return nil, ErrTagNotFound
+	}
+
+	return tags, nil
+}
+
+func addTaskTags(ctx context.Context, q *Queries, taskID int64, tags []Tag) error {
+	for _, tag := range tags {
+		err := q.AddTaskTag(ctx, AddTaskTagParams{
+			TaskID: taskID,
+			TagID:  tag.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidResetCode  = errors.New("the reset code is invalid, expired or was already used")
+	ErrInvalidVerifyCode = errors.New("the verification code is invalid, expired or was already used")
+	ErrTotpEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMfaToken   = errors.New("the mfa token is invalid, expired or was already used")
+	ErrInvalidMfaCode    = errors.New("the authentication code is invalid or was already used")
+)
+
+// CreateUserTxParams contains the input parameters of the create user transaction
+type CreateUserTxParams struct {
+	CreateUserParams
+	VerifyCodeHash  string    `json:"verify_code_hash"`
+	VerifyExpiresAt time.Time `json:"verify_expires_at"`
+	// AfterCreate runs inside the transaction once the user and its
+	// verification code exist, the user isn't created if it fails. It runs
+	// again when the transaction is retried and it must be bounded, since the
+	// transaction stays open until it returns
+	AfterCreate func(user User, verifyEmail VerifyEmail) error `json:"-"`
+}
+
+// CreateUserTxResult is the result of the create user transaction
+type CreateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates a user together with the code to verify its email
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:  result.User.Username,
+			Email:     result.User.Email,
+			CodeHash:  arg.VerifyCodeHash,
+			ExpiresAt: arg.VerifyExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.AfterCreate == nil {
+			return nil
+		}
+		return arg.AfterCreate(result.User, result.VerifyEmail)
+	})
+
+	return result, err
+}
+
+// UpdateUserTxParams contains the input parameters of the update user transaction
+type UpdateUserTxParams struct {
+	UpdateUserProfileParams
+	// VerifyCodeHash is set when the email changes so the new email gets a
+	// verification code, AfterUpdate runs inside the transaction once it exists
+	// and it must be bounded, since the transaction stays open until it returns
+	VerifyCodeHash  string                                         `json:"verify_code_hash"`
+	VerifyExpiresAt time.Time                                      `json:"verify_expires_at"`
+	AfterUpdate     func(user User, verifyEmail VerifyEmail) error `json:"-"`
+}
+
+// UpdateUserTxResult is the result of the update user transaction
+type UpdateUserTxResult struct {
+	User        User         `json:"user"`
+	VerifyEmail *VerifyEmail `json:"verify_email"`
+}
+
+// UpdateUserTx updates the profile of a user and creates the code to verify
+// its new email when it changes
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = UpdateUserTxResult{}
+
+		user, err := q.UpdateUserProfile(ctx, arg.UpdateUserProfileParams)
+		if err != nil {
+			return err
+		}
+		result.User = user
+
+		if arg.VerifyCodeHash == "" {
+			return nil
+		}
+
+		verifyEmail, err := q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:  user.Username,
+			Email:     user.Email,
+			CodeHash:  arg.VerifyCodeHash,
+			ExpiresAt: arg.VerifyExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		result.VerifyEmail = &verifyEmail
+
+		if arg.AfterUpdate == nil {
+			return nil
+		}
+		return arg.AfterUpdate(user, verifyEmail)
+	})
+
+	return result, err
+}
+
+// VerifyEmailTxResult is the result of the verify email transaction
+type VerifyEmailTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// VerifyEmailTx uses a verification code and marks the email of its user as
+// verified. The code is rejected if the user changed its email since then
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.VerifyEmail, err = q.UseVerifyEmail(ctx, arg)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidVerifyCode
+			}
+			return err
+		}
+
+		result.User, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidVerifyCode
+		}
+		return err
+	})
+
+	return result, err
+}
+
+// DeleteUserTx deletes a user together with all its tasks, tags, projects,
+// sessions, password resets and verification codes
+func (store *SQLStore) DeleteUserTx(ctx context.Context, username string) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		err := q.DeleteSessionsByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeletePasswordResetsByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteVerifyEmailsByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteTasksByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteTagsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteProjectsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		return q.DeleteUser(ctx, username)
+	})
+}
+
+// ExportUserTxResult is the result of the export user transaction
+type ExportUserTxResult struct {
+	User     User      `json:"user"`
+	Projects []Project `json:"projects"`
+	Tags     []Tag     `json:"tags"`
+	Tasks    []Task    `json:"tasks"`
+	TaskTags []TaskTag `json:"task_tags"`
+	Sessions []Session `json:"sessions"`
+}
+
+// ExportUserTx gets a user together with all its projects, tags, tasks and sessions
+func (store *SQLStore) ExportUserTx(ctx context.Context, username string) (ExportUserTxResult, error) {
+	var result ExportUserTxResult
+
+	// every query reads the same snapshot, so the export can't mix rows from
+	// before and after a concurrent change
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := store.execTxOptions(ctx, opts, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		result.Projects, err = q.ListProjectsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		result.Tags, err = q.ListTagsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		result.Tasks, err = q.ListTasksByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		result.TaskTags, err = q.ListTaskTagsByOwner(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		result.Sessions, err = q.ListSessionsByUsername(ctx, username)
+		return err
+	})
+
+	return result, err
+}
+
+// ResetPasswordTxParams contains the input parameters of the reset password transaction
+type ResetPasswordTxParams struct {
+	CodeHash          string    `json:"code_hash"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+// ResetPasswordTxResult is the result of the reset password transaction
+type ResetPasswordTxResult struct {
+	User     User      `json:"user"`
+	Sessions []Session `json:"sessions"`
+}
+
+// ResetPasswordTx uses a reset code, changes the password of its user and
+// blocks all the sessions of the user. Neither the code nor the other pending
+// codes of the user can be used again
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		reset, err := q.UsePasswordReset(ctx, arg.CodeHash)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidResetCode
+			}
+			return err
+		}
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:          reset.Username,
+			HashedPassword:    arg.HashedPassword,
+			PasswordChangedAt: arg.PasswordChangedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeletePasswordResetsByUsername(ctx, reset.Username)
+		if err != nil {
+			return err
+		}
+
+		result.Sessions, err = q.BlockSessionsByUsername(ctx, reset.Username)
+		return err
+	})
+
+	return result, err
+}
+
+// EnrollTotpTxParams contains the input parameters of the enroll totp transaction
+type EnrollTotpTxParams struct {
+	CreateUserTotpParams
+	RecoveryCodeHashes []string `json:"recovery_code_hashes"`
+}
+
+// EnrollTotpTx stores a new unconfirmed totp secret of a user and replaces its
+// recovery codes. It fails with ErrTotpEnabled once the secret was confirmed
+func (store *SQLStore) EnrollTotpTx(ctx context.Context, arg EnrollTotpTxParams) (UserTotp, error) {
+	var userTotp UserTotp
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		userTotp, err = q.CreateUserTotp(ctx, arg.CreateUserTotpParams)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTotpEnabled
+			}
+			return err
+		}
+
+		err = q.DeleteRecoveryCodesByUsername(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		for _, codeHash := range arg.RecoveryCodeHashes {
+			_, err = q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username: arg.Username,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return userTotp, err
+}
+
+// UseMfaChallengeTxParams contains the input parameters of the use mfa
+// challenge transaction. RecoveryCodeHash is set when a recovery code is
+// used, TotpStep is the step of the totp code otherwise
+type UseMfaChallengeTxParams struct {
+	ChallengeID      int64  `json:"challenge_id"`
+	Username         string `json:"username"`
+	TotpStep         int64  `json:"totp_step"`
+	RecoveryCodeHash string `json:"recovery_code_hash"`
+}
+
+// UseMfaChallengeTx uses an mfa challenge together with the totp step or the
+// recovery code that passed it, so a code is never spent on a challenge that
+// another request already used. It fails with ErrInvalidMfaToken when the
+// challenge was used and with ErrInvalidMfaCode when the code was
+func (store *SQLStore) UseMfaChallengeTx(ctx context.Context, arg UseMfaChallengeTxParams) (MfaChallenge, error) {
+	var challenge MfaChallenge
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		challenge, err = q.UseMfaChallenge(ctx, arg.ChallengeID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidMfaToken
+			}
+			return err
+		}
+
+		if arg.RecoveryCodeHash != "" {
+			_, err = q.UseRecoveryCode(ctx, UseRecoveryCodeParams{
+				Username: arg.Username,
+				CodeHash: arg.RecoveryCodeHash,
+			})
+		} else {
+			_, err = q.UseTotpStep(ctx, UseTotpStepParams{
+				Username:     arg.Username,
+				LastUsedStep: arg.TotpStep,
+			})
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidMfaCode
+		}
+		return err
+	})
+
+	return challenge, err
+}
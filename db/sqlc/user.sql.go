@@ -7,12 +7,13 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username,email,hashed_password)
 VALUES ($1,$2,$3)
-RETURNING username, email, hashed_password, password_changed_at, created_at
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
 `
 
 type CreateUserParams struct {
@@ -30,6 +31,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
@@ -45,7 +53,7 @@ func (q *Queries) DeleteUser(ctx context.Context, username string) error {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, email, hashed_password, password_changed_at, created_at FROM users
+SELECT username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after FROM users
 WHERE username = $1 
 LIMIT 1
 `
@@ -59,24 +67,61 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.HashedPassword,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT username, email, hashed_password, password_changed_at, created_at FROM users
+SELECT username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after FROM users
+WHERE $1::varchar = ''
+OR username ILIKE '%' || $1 || '%'
+OR email ILIKE '%' || $1 || '%'
 ORDER BY username
-LIMIT $1
-OFFSET $2
+LIMIT $2
+OFFSET $3
 `
 
 type ListUsersParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	Search string `json:"search"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
 }
 
 func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Search, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +135,13 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 			&i.HashedPassword,
 			&i.PasswordChangedAt,
 			&i.CreatedAt,
+			&i.IsEmailVerified,
+			&i.FullName,
+			&i.Timezone,
+			&i.Locale,
+			&i.Role,
+			&i.LockedAt,
+			&i.TokensValidAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -104,12 +156,83 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 	return items, nil
 }
 
+const lockUser = `-- name: LockUser :one
+UPDATE users
+SET locked_at = COALESCE(locked_at, now()),
+tokens_valid_after = now()
+WHERE username = $1
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
+`
+
+func (q *Queries) LockUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, lockUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE users
+SET tokens_valid_after = GREATEST(tokens_valid_after, $1::timestamptz)
+WHERE username = $2
+`
+
+type RevokeUserTokensParams struct {
+	IssuedBefore time.Time `json:"issued_before"`
+	Username     string    `json:"username"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, arg.IssuedBefore, arg.Username)
+	return err
+}
+
+const unlockUser = `-- name: UnlockUser :one
+UPDATE users
+SET locked_at = NULL
+WHERE username = $1
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
+`
+
+func (q *Queries) UnlockUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, unlockUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $2,
 hashed_password = $3
 WHERE username = $1
-RETURNING username, email, hashed_password, password_changed_at, created_at
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
 `
 
 type UpdateUserParams struct {
@@ -127,6 +250,127 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.HashedPassword,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+password_changed_at = $3
+WHERE username = $1
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.Username, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET email = $2,
+full_name = $3,
+timezone = $4,
+locale = $5,
+is_email_verified = $6
+WHERE username = $1
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
+`
+
+type UpdateUserProfileParams struct {
+	Username        string `json:"username"`
+	Email           string `json:"email"`
+	FullName        string `json:"full_name"`
+	Timezone        string `json:"timezone"`
+	Locale          string `json:"locale"`
+	IsEmailVerified bool   `json:"is_email_verified"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Username,
+		arg.Email,
+		arg.FullName,
+		arg.Timezone,
+		arg.Locale,
+		arg.IsEmailVerified,
+	)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = TRUE
+WHERE username = $1
+AND email = $2
+RETURNING username, email, hashed_password, password_changed_at, created_at, is_email_verified, full_name, timezone, locale, role, locked_at, tokens_valid_after
+`
+
+type VerifyUserEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Username, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.Email,
+		&i.HashedPassword,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.FullName,
+		&i.Timezone,
+		&i.Locale,
+		&i.Role,
+		&i.LockedAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
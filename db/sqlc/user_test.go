@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +31,10 @@ func createRandomUser(t *testing.T) User {
 
 	require.NotZero(t, user.CreatedAt)
 	require.True(t, user.PasswordChangedAt.IsZero())
+	require.False(t, user.IsEmailVerified)
+	require.Empty(t, user.FullName)
+	require.Equal(t, "UTC", user.Timezone)
+	require.Equal(t, "en", user.Locale)
 
 	return user
 }
@@ -52,6 +57,17 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user1.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
 }
 
+func TestGetUserByEmail(t *testing.T) {
+	user1 := createRandomUser(t)
+	user2, err := testQueries.GetUserByEmail(context.Background(), user1.Email)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.Equal(t, user1.Email, user2.Email)
+
+	_, err = testQueries.GetUserByEmail(context.Background(), util.RandomEmail())
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
 func TestListUsers(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		createRandomUser(t)
@@ -71,6 +87,59 @@ func TestListUsers(t *testing.T) {
 	}
 }
 
+func TestListUsersSearch(t *testing.T) {
+	user := createRandomUser(t)
+
+	testCases := []string{
+		user.Username,
+		strings.ToUpper(user.Username),
+		user.Email,
+	}
+
+	for _, search := range testCases {
+		users, err := testQueries.ListUsers(context.Background(), ListUsersParams{
+			Search: search,
+			Limit:  100,
+			Offset: 0,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, users)
+
+		found := false
+		for _, u := range users {
+			match := strings.Contains(strings.ToLower(u.Username), strings.ToLower(search)) ||
+				strings.Contains(strings.ToLower(u.Email), strings.ToLower(search))
+			require.True(t, match)
+			found = found || u.Username == user.Username
+		}
+		require.True(t, found)
+	}
+}
+
+func TestLockUser(t *testing.T) {
+	user1 := createRandomUser(t)
+	require.Equal(t, util.UserRole, user1.Role)
+	require.Nil(t, user1.LockedAt)
+
+	user2, err := testQueries.LockUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.NotNil(t, user2.LockedAt)
+	require.NotNil(t, user2.TokensValidAfter)
+
+	// locking again keeps the first lock time
+	user3, err := testQueries.LockUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, *user2.LockedAt, *user3.LockedAt)
+
+	user3, err = testQueries.UnlockUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Nil(t, user3.LockedAt)
+	require.NotNil(t, user3.TokensValidAfter)
+
+	_, err = testQueries.LockUser(context.Background(), util.RandomUsername())
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
 func TestUpdateUser(t *testing.T) {
 	hashedPassword, err := util.HashPassword(util.RandomPassword(16))
 	require.NoError(t, err)
@@ -105,3 +174,113 @@ func TestDeleteUser(t *testing.T) {
 	require.EqualError(t, err, sql.ErrNoRows.Error())
 	require.Empty(t, user2)
 }
+
+func TestDeleteUserCascade(t *testing.T) {
+	user := createRandomUser(t)
+	task := createRandomTaskWithOwner(t, user.Username, "Delete user cascade")
+	tag := createRandomTag(t, user.Username)
+	project := createRandomProject(t, user.Username)
+	session := createRandomSession(t, user.Username)
+
+	// the owned rows are removed by the foreign keys
+	err := testQueries.DeleteUser(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetTask(context.Background(), task.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetTag(context.Background(), tag.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetProject(context.Background(), project.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetSession(context.Background(), session.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestUpdateUserProfile(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	arg := UpdateUserProfileParams{
+		Username:        user1.Username,
+		Email:           util.RandomEmail(),
+		FullName:        "Nicolas Moreno",
+		Timezone:        "America/Bogota",
+		Locale:          "es-CO",
+		IsEmailVerified: false,
+	}
+
+	user2, err := testQueries.UpdateUserProfile(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.Equal(t, arg.Email, user2.Email)
+	require.Equal(t, arg.FullName, user2.FullName)
+	require.Equal(t, arg.Timezone, user2.Timezone)
+	require.Equal(t, arg.Locale, user2.Locale)
+	require.Equal(t, user1.HashedPassword, user2.HashedPassword)
+
+	// emails are unique
+	user3 := createRandomUser(t)
+	arg.Username = user3.Username
+	_, err = testQueries.UpdateUserProfile(context.Background(), arg)
+	require.Equal(t, UniqueViolation, ErrorCode(err))
+}
+
+func TestUpdateUserTx(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	var sent VerifyEmail
+	arg := UpdateUserTxParams{
+		UpdateUserProfileParams: UpdateUserProfileParams{
+			Username: user1.Username,
+			Email:    util.RandomEmail(),
+			FullName: "Nicolas Moreno",
+			Timezone: user1.Timezone,
+			Locale:   user1.Locale,
+		},
+		VerifyCodeHash:  util.HashToken(util.RandomPassword(32)),
+		VerifyExpiresAt: time.Now().Add(time.Minute),
+		AfterUpdate: func(user User, verifyEmail VerifyEmail) error {
+			sent = verifyEmail
+			return nil
+		},
+	}
+
+	result, err := testQueries.UpdateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Email, result.User.Email)
+	require.Equal(t, arg.FullName, result.User.FullName)
+	require.NotNil(t, result.VerifyEmail)
+	require.Equal(t, arg.Email, result.VerifyEmail.Email)
+	require.Equal(t, result.VerifyEmail.ID, sent.ID)
+
+	// without a new email there is nothing to verify
+	arg.FullName = "Nicolas"
+	arg.VerifyCodeHash = ""
+	result, err = testQueries.UpdateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.FullName, result.User.FullName)
+	require.Nil(t, result.VerifyEmail)
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	hashedPassword, err := util.HashPassword(util.RandomPassword(16))
+	require.NoError(t, err)
+
+	arg := UpdateUserPasswordParams{
+		Username:          user1.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	user2, err := testQueries.UpdateUserPassword(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.Equal(t, user1.Email, user2.Email)
+	require.Equal(t, hashedPassword, user2.HashedPassword)
+	require.WithinDuration(t, arg.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
+	require.WithinDuration(t, user1.CreatedAt, user2.CreatedAt, time.Second)
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomVerifyEmail(t *testing.T, user User, expiresAt time.Time) (VerifyEmail, string) {
+	code, err := util.SecureToken(32)
+	require.NoError(t, err)
+
+	arg := CreateVerifyEmailParams{
+		Username:  user.Username,
+		Email:     user.Email,
+		CodeHash:  util.HashToken(code),
+		ExpiresAt: expiresAt,
+	}
+
+	verifyEmail, err := testQueries.CreateVerifyEmail(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, verifyEmail.ID)
+	require.Equal(t, arg.Username, verifyEmail.Username)
+	require.Equal(t, arg.Email, verifyEmail.Email)
+	require.Equal(t, arg.CodeHash, verifyEmail.CodeHash)
+	require.False(t, verifyEmail.IsUsed)
+	require.WithinDuration(t, arg.ExpiresAt, verifyEmail.ExpiresAt, time.Second)
+	require.NotZero(t, verifyEmail.CreatedAt)
+
+	return verifyEmail, code
+}
+
+func TestCreateVerifyEmail(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomVerifyEmail(t, user, time.Now().Add(time.Minute))
+}
+
+func TestUseVerifyEmail(t *testing.T) {
+	user := createRandomUser(t)
+	verifyEmail1, code := createRandomVerifyEmail(t, user, time.Now().Add(time.Minute))
+
+	_, err := testQueries.UseVerifyEmail(context.Background(), UseVerifyEmailParams{
+		ID:       verifyEmail1.ID,
+		CodeHash: util.HashToken("incorrect code"),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	arg := UseVerifyEmailParams{
+		ID:       verifyEmail1.ID,
+		CodeHash: util.HashToken(code),
+	}
+
+	verifyEmail2, err := testQueries.UseVerifyEmail(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, verifyEmail1.ID, verifyEmail2.ID)
+	require.True(t, verifyEmail2.IsUsed)
+
+	// a code can be used only once
+	_, err = testQueries.UseVerifyEmail(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	expired, code := createRandomVerifyEmail(t, user, time.Now().Add(-time.Minute))
+	_, err = testQueries.UseVerifyEmail(context.Background(), UseVerifyEmailParams{
+		ID:       expired.ID,
+		CodeHash: util.HashToken(code),
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestCreateUserTx(t *testing.T) {
+	hashedPassword, err := util.HashPassword(util.RandomPassword(16))
+	require.NoError(t, err)
+
+	var sent VerifyEmail
+	arg := CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomUsername(),
+			Email:          util.RandomEmail(),
+			HashedPassword: hashedPassword,
+		},
+		VerifyCodeHash:  util.HashToken(util.RandomPassword(32)),
+		VerifyExpiresAt: time.Now().Add(time.Minute),
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			sent = verifyEmail
+			return nil
+		},
+	}
+
+	result, err := testQueries.CreateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, result.User.Username)
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, result.User.Username, result.VerifyEmail.Username)
+	require.Equal(t, result.User.Email, result.VerifyEmail.Email)
+	require.Equal(t, arg.VerifyCodeHash, result.VerifyEmail.CodeHash)
+	require.Equal(t, result.VerifyEmail.ID, sent.ID)
+}
+
+func TestCreateUserTxAfterCreateError(t *testing.T) {
+	hashedPassword, err := util.HashPassword(util.RandomPassword(16))
+	require.NoError(t, err)
+
+	errMailer := errors.New("cannot send the email")
+	arg := CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomUsername(),
+			Email:          util.RandomEmail(),
+			HashedPassword: hashedPassword,
+		},
+		VerifyCodeHash:  util.HashToken(util.RandomPassword(32)),
+		VerifyExpiresAt: time.Now().Add(time.Minute),
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			return errMailer
+		},
+	}
+
+	_, err = testQueries.CreateUserTx(context.Background(), arg)
+	require.ErrorIs(t, err, errMailer)
+
+	// the user is rolled back together with its verification code
+	_, err = testQueries.GetUser(context.Background(), arg.Username)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	user := createRandomUser(t)
+	verifyEmail, code := createRandomVerifyEmail(t, user, time.Now().Add(time.Minute))
+
+	arg := UseVerifyEmailParams{
+		ID:       verifyEmail.ID,
+		CodeHash: util.HashToken(code),
+	}
+
+	result, err := testQueries.VerifyEmailTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, result.User.Username)
+	require.True(t, result.User.IsEmailVerified)
+	require.True(t, result.VerifyEmail.IsUsed)
+
+	_, err = testQueries.VerifyEmailTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidVerifyCode)
+}
+
+func TestVerifyEmailTxChangedEmail(t *testing.T) {
+	user1 := createRandomUser(t)
+	verifyEmail, code := createRandomVerifyEmail(t, user1, time.Now().Add(time.Minute))
+
+	user2, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username:       user1.Username,
+		Email:          util.RandomEmail(),
+		HashedPassword: user1.HashedPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.VerifyEmailTx(context.Background(), UseVerifyEmailParams{
+		ID:       verifyEmail.ID,
+		CodeHash: util.HashToken(code),
+	})
+	require.ErrorIs(t, err, ErrInvalidVerifyCode)
+
+	user3, err := testQueries.GetUser(context.Background(), user2.Username)
+	require.NoError(t, err)
+	require.False(t, user3.IsEmailVerified)
+}
@@ -0,0 +1,140 @@
+package denylist
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/google/uuid"
+)
+
+// CachedDenylist keeps the latest lookups of another denylist in a LRU cache.
+// Revoked tokens stay cached until they are evicted because a token can't be
+// restored, while tokens that weren't revoked are checked again after the ttl
+// so revocations made by other servers are seen
+type CachedDenylist struct {
+	next Denylist
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	order   *list.List
+}
+
+type cacheEntry struct {
+	id       uuid.UUID
+	username string
+	revoked  bool
+	cachedAt time.Time
+}
+
+// NewCachedDenylist creates a new denylist that caches up to size lookups of next
+func NewCachedDenylist(next Denylist, size int, ttl time.Duration) Denylist {
+	return &CachedDenylist{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[uuid.UUID]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (cache *CachedDenylist) Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	err := cache.next.Revoke(ctx, id, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	cache.set(id, "", true)
+	return nil
+}
+
+// RevokeIssuedBefore forgets the tokens of the user that weren't revoked, so
+// their next lookup reaches the underlying denylist
+func (cache *CachedDenylist) RevokeIssuedBefore(ctx context.Context, username string, before time.Time) error {
+	err := cache.next.RevokeIssuedBefore(ctx, username, before)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for element := cache.order.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*cacheEntry)
+		if entry.username == username && !entry.revoked {
+			cache.order.Remove(element)
+			delete(cache.entries, entry.id)
+		}
+		element = next
+	}
+	return nil
+}
+
+func (cache *CachedDenylist) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	revoked, ok := cache.get(payload.ID)
+	if ok {
+		return revoked, nil
+	}
+
+	revoked, err := cache.next.IsRevoked(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+
+	cache.set(payload.ID, payload.Username, revoked)
+	return revoked, nil
+}
+
+func (cache *CachedDenylist) get(id uuid.UUID) (revoked bool, ok bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[id]
+	if !ok {
+		return false, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !entry.revoked && time.Since(entry.cachedAt) > cache.ttl {
+		cache.order.Remove(element)
+		delete(cache.entries, id)
+		return false, false
+	}
+
+	cache.order.MoveToFront(element)
+	return entry.revoked, true
+}
+
+func (cache *CachedDenylist) set(id uuid.UUID, username string, revoked bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[id]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.revoked = entry.revoked || revoked
+		if username != "" {
+			entry.username = username
+		}
+		entry.cachedAt = time.Now()
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[id] = cache.order.PushFront(&cacheEntry{
+		id:       id,
+		username: username,
+		revoked:  revoked,
+		cachedAt: time.Now(),
+	})
+
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
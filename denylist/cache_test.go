@@ -0,0 +1,147 @@
+package denylist
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDenylist counts the lookups that reach the underlying denylist
+type countingDenylist struct {
+	Denylist
+	lookups int
+	err     error
+}
+
+func (denylist *countingDenylist) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	denylist.lookups++
+	if denylist.err != nil {
+		return false, denylist.err
+	}
+	return denylist.Denylist.IsRevoked(ctx, payload)
+}
+
+func TestCachedDenylist(t *testing.T) {
+	next := &countingDenylist{Denylist: NewMemoryDenylist()}
+	cache := NewCachedDenylist(next, 10, time.Minute)
+	payload := randomPayload(t, util.RandomUsername())
+
+	for i := 0; i < 3; i++ {
+		revoked, err := cache.IsRevoked(context.Background(), payload)
+		require.NoError(t, err)
+		require.False(t, revoked)
+	}
+	require.Equal(t, 1, next.lookups)
+
+	// revoking through the cache must not serve the stale lookup
+	err := cache.Revoke(context.Background(), payload.ID, payload.ExpiresAt)
+	require.NoError(t, err)
+
+	revoked, err := cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 1, next.lookups)
+
+	revoked, err = next.Denylist.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestCachedDenylistTTL(t *testing.T) {
+	next := &countingDenylist{Denylist: NewMemoryDenylist()}
+	cache := NewCachedDenylist(next, 10, 10*time.Millisecond)
+	payload := randomPayload(t, util.RandomUsername())
+
+	revoked, err := cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	// another server revokes the token
+	err = next.Revoke(context.Background(), payload.ID, payload.ExpiresAt)
+	require.NoError(t, err)
+
+	revoked, err = cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	time.Sleep(20 * time.Millisecond)
+
+	revoked, err = cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 2, next.lookups)
+}
+
+func TestCachedDenylistEviction(t *testing.T) {
+	next := &countingDenylist{Denylist: NewMemoryDenylist()}
+	cache := NewCachedDenylist(next, 2, time.Minute)
+
+	payload1 := randomPayload(t, util.RandomUsername())
+	payload2 := randomPayload(t, util.RandomUsername())
+	payload3 := randomPayload(t, util.RandomUsername())
+	for _, payload := range []*token.Payload{payload1, payload2, payload1, payload3} {
+		_, err := cache.IsRevoked(context.Background(), payload)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, next.lookups)
+
+	// payload2 was the least recently used so it was evicted
+	_, err := cache.IsRevoked(context.Background(), payload1)
+	require.NoError(t, err)
+	require.Equal(t, 3, next.lookups)
+
+	_, err = cache.IsRevoked(context.Background(), payload2)
+	require.NoError(t, err)
+	require.Equal(t, 4, next.lookups)
+}
+
+func TestCachedDenylistError(t *testing.T) {
+	next := &countingDenylist{Denylist: NewMemoryDenylist(), err: sql.ErrConnDone}
+	cache := NewCachedDenylist(next, 10, time.Minute)
+	payload := randomPayload(t, util.RandomUsername())
+
+	_, err := cache.IsRevoked(context.Background(), payload)
+	require.ErrorIs(t, err, sql.ErrConnDone)
+
+	// errors are never cached
+	next.err = nil
+	revoked, err := cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+	require.Equal(t, 2, next.lookups)
+}
+
+func TestCachedDenylistRevokeIssuedBefore(t *testing.T) {
+	next := &countingDenylist{Denylist: NewMemoryDenylist()}
+	cache := NewCachedDenylist(next, 10, time.Minute)
+
+	username := util.RandomUsername()
+	payload := randomPayload(t, username)
+	otherPayload := randomPayload(t, util.RandomUsername())
+
+	for _, p := range []*token.Payload{payload, otherPayload} {
+		revoked, err := cache.IsRevoked(context.Background(), p)
+		require.NoError(t, err)
+		require.False(t, revoked)
+	}
+	require.Equal(t, 2, next.lookups)
+
+	err := cache.RevokeIssuedBefore(context.Background(), username, time.Now())
+	require.NoError(t, err)
+
+	// the cached lookup of the user is dropped, the other one is kept
+	revoked, err := cache.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.Equal(t, 3, next.lookups)
+
+	revoked, err = cache.IsRevoked(context.Background(), otherPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+	require.Equal(t, 3, next.lookups)
+}
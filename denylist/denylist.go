@@ -0,0 +1,105 @@
+package denylist
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/google/uuid"
+)
+
+// Denylist keeps the ids of the tokens that were revoked before they expired
+type Denylist interface {
+	// Revoke denies the token with the given id until it expires
+	Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+
+	// RevokeIssuedBefore denies every token of the user issued before the given time
+	RevokeIssuedBefore(ctx context.Context, username string, before time.Time) error
+
+	// IsRevoked checks if the token was revoked by its id or by its issue time
+	IsRevoked(ctx context.Context, payload *token.Payload) (bool, error)
+}
+
+// StoreDenylist keeps the revoked tokens in the database so they are shared by every server
+type StoreDenylist struct {
+	store db.Querier
+}
+
+// NewStoreDenylist creates a new denylist backed by the database
+func NewStoreDenylist(store db.Querier) Denylist {
+	return &StoreDenylist{store: store}
+}
+
+func (denylist *StoreDenylist) Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	return denylist.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        id,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// RevokeIssuedBefore moves the tokens_valid_after cutoff of the user forward, it
+// never moves it back. The database also denies the tokens of users that don't
+// exist and the tokens issued before the password changed or the user was
+// created, so the tokens of a deleted user don't work for a new user with the
+// same username
+func (denylist *StoreDenylist) RevokeIssuedBefore(ctx context.Context, username string, before time.Time) error {
+	return denylist.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		IssuedBefore: before,
+		Username:     username,
+	})
+}
+
+func (denylist *StoreDenylist) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	return denylist.store.IsTokenRevoked(ctx, db.IsTokenRevokedParams{
+		ID:       payload.ID,
+		Username: payload.Username,
+		IssuedAt: payload.IssuedAt,
+	})
+}
+
+// MemoryDenylist keeps the revoked tokens in memory, it's meant for tests and single instances
+type MemoryDenylist struct {
+	mu      sync.RWMutex
+	revoked map[uuid.UUID]time.Time
+	cutoffs map[string]time.Time
+}
+
+// NewMemoryDenylist creates a new in-process denylist
+func NewMemoryDenylist() Denylist {
+	return &MemoryDenylist{
+		revoked: make(map[uuid.UUID]time.Time),
+		cutoffs: make(map[string]time.Time),
+	}
+}
+
+func (denylist *MemoryDenylist) Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	denylist.revoked[id] = expiresAt
+	return nil
+}
+
+func (denylist *MemoryDenylist) RevokeIssuedBefore(ctx context.Context, username string, before time.Time) error {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	if before.After(denylist.cutoffs[username]) {
+		denylist.cutoffs[username] = before
+	}
+	return nil
+}
+
+func (denylist *MemoryDenylist) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	denylist.mu.RLock()
+	defer denylist.mu.RUnlock()
+
+	if _, ok := denylist.revoked[payload.ID]; ok {
+		return true, nil
+	}
+
+	cutoff, ok := denylist.cutoffs[payload.Username]
+	return ok && payload.IssuedAt.Before(cutoff), nil
+}
@@ -0,0 +1,115 @@
+package denylist
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/DMV-Nicolas/DevoraTasks/db/mock"
+	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPayload(t *testing.T, username string) *token.Payload {
+	payload, err := token.NewPayload(username, util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestMemoryDenylist(t *testing.T) {
+	denylist := NewMemoryDenylist()
+	payload := randomPayload(t, util.RandomUsername())
+
+	revoked, err := denylist.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	err = denylist.Revoke(context.Background(), payload.ID, payload.ExpiresAt)
+	require.NoError(t, err)
+
+	revoked, err = denylist.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = denylist.IsRevoked(context.Background(), randomPayload(t, payload.Username))
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestMemoryDenylistRevokeIssuedBefore(t *testing.T) {
+	denylist := NewMemoryDenylist()
+	username := util.RandomUsername()
+
+	oldPayload := randomPayload(t, username)
+	otherPayload := randomPayload(t, util.RandomUsername())
+
+	err := denylist.RevokeIssuedBefore(context.Background(), username, time.Now())
+	require.NoError(t, err)
+
+	newPayload := randomPayload(t, username)
+
+	revoked, err := denylist.IsRevoked(context.Background(), oldPayload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = denylist.IsRevoked(context.Background(), newPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	revoked, err = denylist.IsRevoked(context.Background(), otherPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestStoreDenylist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	denylist := NewStoreDenylist(store)
+	payload := randomPayload(t, util.RandomUsername())
+
+	store.EXPECT().
+		RevokeToken(gomock.Any(), gomock.Eq(db.RevokeTokenParams{ID: payload.ID, ExpiresAt: payload.ExpiresAt})).
+		Times(1).
+		Return(nil)
+	store.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Eq(db.IsTokenRevokedParams{
+			ID:       payload.ID,
+			Username: payload.Username,
+			IssuedAt: payload.IssuedAt,
+		})).
+		Times(1).
+		Return(true, nil)
+
+	err := denylist.Revoke(context.Background(), payload.ID, payload.ExpiresAt)
+	require.NoError(t, err)
+
+	revoked, err := denylist.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	store.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(false, sql.ErrConnDone)
+
+	_, err = denylist.IsRevoked(context.Background(), randomPayload(t, payload.Username))
+	require.ErrorIs(t, err, sql.ErrConnDone)
+
+	before := time.Now()
+	store.EXPECT().
+		RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{
+			IssuedBefore: before,
+			Username:     payload.Username,
+		})).
+		Times(1).
+		Return(nil)
+
+	err = denylist.RevokeIssuedBefore(context.Background(), payload.Username, before)
+	require.NoError(t, err)
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer writes the emails to a writer instead of sending them, it's meant
+// for local development and tests
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogMailer creates a new mailer that writes the emails to w
+func NewLogMailer(w io.Writer) Mailer {
+	return &LogMailer{w: w}
+}
+
+func (mailer *LogMailer) SendEmail(ctx context.Context, email Email) error {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	_, err := fmt.Fprintf(mailer.w, "%s email to %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339),
+		strings.Join(email.To, ", "),
+		email.Subject,
+		email.Body,
+	)
+	return err
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+)
+
+// Email contains the data of a plain text email
+type Email struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer is an interface for sending emails
+type Mailer interface {
+	// SendEmail sends the email to all its recipients
+	SendEmail(ctx context.Context, email Email) error
+}
+
+// NewMailer creates the mailer selected in the config, the log mailer is used
+// when none is selected so local development doesn't need a SMTP server
+func NewMailer(config util.Config) (Mailer, error) {
+	switch config.Mailer {
+	case "smtp":
+		return NewSMTPMailer(
+			config.SMTPHost,
+			config.SMTPPort,
+			config.SMTPUsername,
+			config.SMTPPassword,
+			config.EmailSenderAddress,
+		), nil
+	case "log", "":
+		if config.MailLogFile == "" {
+			return NewLogMailer(os.Stdout), nil
+		}
+
+		file, err := os.OpenFile(config.MailLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return NewLogMailer(file), nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer %s", config.Mailer)
+	}
+}
@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer(&buf)
+
+	email := Email{
+		To:      []string{util.RandomEmail()},
+		Subject: "Reset your password",
+		Body:    "Your code is 123456",
+	}
+
+	err := mailer.SendEmail(context.Background(), email)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), email.To[0])
+	require.Contains(t, buf.String(), email.Subject)
+	require.Contains(t, buf.String(), email.Body)
+}
+
+func TestBuildMessage(t *testing.T) {
+	email := Email{
+		To:      []string{"pepito@gmail.com", "nicolas@gmail.com"},
+		Subject: "Reset your password",
+		Body:    "Hello\nYour code is 123456",
+	}
+
+	msg, err := buildMessage("no-reply@devoratasks.com", email)
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "From: no-reply@devoratasks.com\r\n")
+	require.Contains(t, string(msg), "To: pepito@gmail.com, nicolas@gmail.com\r\n")
+	require.Contains(t, string(msg), "Subject: Reset your password\r\n")
+	require.Contains(t, string(msg), "\r\n\r\nHello\r\nYour code is 123456")
+
+	_, err = buildMessage("no-reply@devoratasks.com", Email{Subject: "No recipients"})
+	require.Error(t, err)
+
+	email.Subject = "Injected\r\nBcc: hacker@gmail.com"
+	_, err = buildMessage("no-reply@devoratasks.com", email)
+	require.Error(t, err)
+}
+
+func TestNewMailer(t *testing.T) {
+	mailer, err := NewMailer(util.Config{})
+	require.NoError(t, err)
+	require.IsType(t, &LogMailer{}, mailer)
+
+	mailer, err = NewMailer(util.Config{Mailer: "log", MailLogFile: filepath.Join(t.TempDir(), "mail.log")})
+	require.NoError(t, err)
+	require.IsType(t, &LogMailer{}, mailer)
+
+	mailer, err = NewMailer(util.Config{Mailer: "smtp", SMTPHost: "localhost", SMTPPort: 25})
+	require.NoError(t, err)
+	require.IsType(t, &SMTPMailer{}, mailer)
+
+	_, err = NewMailer(util.Config{Mailer: "pigeon"})
+	require.Error(t, err)
+}
+
+func TestSMTPMailerStalledServer(t *testing.T) {
+	// the server accepts the connection but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer := NewSMTPMailer("127.0.0.1", addr.Port, "", "", "no-reply@devoratasks.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = mailer.SendEmail(ctx, Email{
+		To:      []string{util.RandomEmail()},
+		Subject: "Reset your password",
+		Body:    "Your code is 123456",
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata"
 
 	"github.com/DMV-Nicolas/DevoraTasks/api"
 	db "github.com/DMV-Nicolas/DevoraTasks/db/sqlc"
@@ -30,7 +35,11 @@ func main() {
 		log.Fatal("Cannot create the server:", err)
 	}
 
-	err = server.Start(config.ServerAddress)
+	// stop gracefully on interrupt, so the emails sent in the background aren't lost
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = server.Start(ctx, config.ServerAddress)
 	if err != nil {
 		log.Fatal("Cannot start server: ", err)
 	}
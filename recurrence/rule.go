@@ -0,0 +1,306 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("recurrence rule is invalid")
+
+// Frequency is the base period of a recurrence rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+const untilLayout = "20060102T150405Z"
+const untilDateLayout = "20060102"
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var weekdayCodes = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Rule is a subset of the RFC 5545 RRULE supporting FREQ, INTERVAL, BYDAY,
+// BYMONTHDAY, COUNT and UNTIL
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	// Count is the number of occurrences left including the current one, zero means forever
+	Count int
+	Until *time.Time
+}
+
+// Parse parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10"
+func Parse(rule string) (*Rule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, fmt.Errorf("%w: rule is empty", ErrInvalidRule)
+	}
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("%w: '%s' should be KEY=VALUE", ErrInvalidRule, part)
+		}
+
+		key = strings.ToUpper(key)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s is repeated", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			err = r.parseFreq(value)
+		case "INTERVAL":
+			r.Interval, err = parsePositive(value)
+		case "BYDAY":
+			err = r.parseByDay(value)
+		case "BYMONTHDAY":
+			err = r.parseByMonthDay(value)
+		case "COUNT":
+			r.Count, err = parsePositive(value)
+		case "UNTIL":
+			err = r.parseUntil(value)
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL can't be used together", ErrInvalidRule)
+	}
+
+	return r, nil
+}
+
+func (r *Rule) parseFreq(value string) error {
+	freq := Frequency(strings.ToUpper(value))
+	switch freq {
+	case Daily, Weekly, Monthly, Yearly:
+		r.Freq = freq
+		return nil
+	}
+	return fmt.Errorf("FREQ '%s' is not supported", value)
+}
+
+func (r *Rule) parseByDay(value string) error {
+	for _, code := range strings.Split(value, ",") {
+		day, ok := weekdays[strings.ToUpper(code)]
+		if !ok {
+			return fmt.Errorf("BYDAY '%s' is not a weekday", code)
+		}
+		r.ByDay = append(r.ByDay, day)
+	}
+	return nil
+}
+
+func (r *Rule) parseByMonthDay(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(s)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return fmt.Errorf("BYMONTHDAY '%s' should be between 1 and 31 or -31 and -1", s)
+		}
+		r.ByMonthDay = append(r.ByMonthDay, day)
+	}
+	return nil
+}
+
+func (r *Rule) parseUntil(value string) error {
+	until, err := time.Parse(untilLayout, value)
+	if err != nil {
+		until, err = time.Parse(untilDateLayout, value)
+		if err != nil {
+			return fmt.Errorf("UNTIL '%s' should look like 20060102T150405Z", value)
+		}
+		// a date only UNTIL includes the whole day
+		until = until.Add(24*time.Hour - time.Second)
+	}
+	r.Until = &until
+	return nil
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("'%s' should be a positive number", value)
+	}
+	return n, nil
+}
+
+// String returns the canonical form of the rule
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = weekdayCodes[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxPeriods bounds the search of the next occurrence, a rule that doesn't
+// match any day in this many periods is considered exhausted
+const maxPeriods = 1000
+
+// Next returns the first occurrence strictly after from, using from as the
+// start of the series. It returns false when the rule has no more occurrences
+func (r *Rule) Next(from time.Time) (time.Time, bool) {
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	for period := 0; period < maxPeriods; period += interval {
+		for _, day := range r.periodDays(from, period) {
+			if day.After(from) && r.matches(day, from) {
+				if r.Until != nil && day.After(*r.Until) {
+					return time.Time{}, false
+				}
+				return day, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Following returns the rule that the next occurrence should carry
+func (r *Rule) Following() *Rule {
+	next := *r
+	if next.Count > 1 {
+		next.Count--
+	}
+	return &next
+}
+
+// periodDays returns every day of the n-th period after the one containing from,
+// keeping the time of the day of from
+func (r *Rule) periodDays(from time.Time, n int) []time.Time {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), from.Location())
+	}
+
+	var start time.Time
+	var end time.Time
+	switch r.Freq {
+	case Daily:
+		start = date(from.Year(), from.Month(), from.Day()+n)
+		end = start.AddDate(0, 0, 1)
+	case Weekly:
+		// weeks start on monday like the RFC 5545 default WKST
+		offset := (int(from.Weekday()) + 6) % 7
+		start = date(from.Year(), from.Month(), from.Day()-offset+7*n)
+		end = start.AddDate(0, 0, 7)
+	case Monthly:
+		start = date(from.Year(), from.Month()+time.Month(n), 1)
+		end = start.AddDate(0, 1, 0)
+	case Yearly:
+		start = date(from.Year()+n, time.January, 1)
+		end = start.AddDate(1, 0, 0)
+	}
+
+	days := []time.Time{}
+	for day := start; day.Before(end); day = date(day.Year(), day.Month(), day.Day()+1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// matches reports whether day is an occurrence, without BYDAY or BYMONTHDAY
+// the occurrences fall on the same position of the period as from
+func (r *Rule) matches(day time.Time, from time.Time) bool {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		switch r.Freq {
+		case Weekly:
+			return day.Weekday() == from.Weekday()
+		case Monthly:
+			return day.Day() == from.Day()
+		case Yearly:
+			return day.Month() == from.Month() && day.Day() == from.Day()
+		}
+		return true
+	}
+
+	if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, day.Weekday()) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		// negative days count backwards from the end of the month
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, monthDay := range r.ByMonthDay {
+			if monthDay == day.Day() || lastDay+monthDay+1 == day.Day() {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	until := time.Date(2024, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		rule string
+		want *Rule
+		err  bool
+	}{
+		{
+			name: "Daily",
+			rule: "FREQ=DAILY",
+			want: &Rule{Freq: Daily, Interval: 1},
+		},
+		{
+			name: "WeeklyByDay",
+			rule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,th;COUNT=10",
+			want: &Rule{Freq: Weekly, Interval: 2, ByDay: []time.Weekday{time.Monday, time.Thursday}, Count: 10},
+		},
+		{
+			name: "MonthlyUntil",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20241231",
+			want: &Rule{Freq: Monthly, Interval: 1, ByMonthDay: []int{1, -1}, Until: &until},
+		},
+		{name: "Empty", rule: "", err: true},
+		{name: "MissingFreq", rule: "INTERVAL=2", err: true},
+		{name: "UnknownFreq", rule: "FREQ=HOURLY", err: true},
+		{name: "UnknownPart", rule: "FREQ=DAILY;BYHOUR=9", err: true},
+		{name: "RepeatedPart", rule: "FREQ=DAILY;FREQ=WEEKLY", err: true},
+		{name: "InvalidInterval", rule: "FREQ=DAILY;INTERVAL=0", err: true},
+		{name: "InvalidByDay", rule: "FREQ=WEEKLY;BYDAY=1MO", err: true},
+		{name: "InvalidByMonthDay", rule: "FREQ=MONTHLY;BYMONTHDAY=32", err: true},
+		{name: "InvalidUntil", rule: "FREQ=DAILY;UNTIL=tomorrow", err: true},
+		{name: "CountAndUntil", rule: "FREQ=DAILY;COUNT=2;UNTIL=20241231", err: true},
+		{name: "MissingValue", rule: "FREQ=", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := Parse(tc.rule)
+			if tc.err {
+				require.ErrorIs(t, err, ErrInvalidRule)
+				require.Nil(t, rule)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, rule)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10",
+		"FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20241231T120000Z",
+	}
+
+	for _, s := range rules {
+		rule, err := Parse(s)
+		require.NoError(t, err)
+		require.Equal(t, s, rule.String())
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-01-31 is a wednesday
+	from := time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 9, 30, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		rule string
+		want time.Time
+		ok   bool
+	}{
+		{rule: "FREQ=DAILY", want: date(time.February, 1), ok: true},
+		{rule: "FREQ=DAILY;INTERVAL=3", want: date(time.February, 3), ok: true},
+		{rule: "FREQ=DAILY;BYDAY=SA,SU", want: date(time.February, 3), ok: true},
+		{rule: "FREQ=WEEKLY", want: date(time.February, 7), ok: true},
+		{rule: "FREQ=WEEKLY;BYDAY=MO,FR", want: date(time.February, 2), ok: true},
+		{rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU", want: date(time.February, 12), ok: true},
+		{rule: "FREQ=MONTHLY", want: date(time.March, 31), ok: true},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=-1", want: date(time.February, 29), ok: true},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=15", want: date(time.February, 15), ok: true},
+		{rule: "FREQ=MONTHLY;BYDAY=MO;BYMONTHDAY=1,2,3,4,5,6,7", want: date(time.February, 5), ok: true},
+		{rule: "FREQ=YEARLY", want: time.Date(2025, time.January, 31, 9, 30, 0, 0, time.UTC), ok: true},
+		{rule: "FREQ=DAILY;COUNT=2", want: date(time.February, 1), ok: true},
+		{rule: "FREQ=DAILY;COUNT=1", ok: false},
+		{rule: "FREQ=DAILY;UNTIL=20240201T093000Z", want: date(time.February, 1), ok: true},
+		{rule: "FREQ=DAILY;UNTIL=20240131", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			rule, err := Parse(tc.rule)
+			require.NoError(t, err)
+
+			next, ok := rule.Next(from)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.want, next)
+		})
+	}
+}
+
+func TestFollowing(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=3")
+	require.NoError(t, err)
+
+	next := rule.Following()
+	require.Equal(t, 2, next.Count)
+	require.Equal(t, 3, rule.Count)
+
+	rule, err = Parse("FREQ=DAILY")
+	require.NoError(t, err)
+	require.Zero(t, rule.Following().Count)
+}
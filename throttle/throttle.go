@@ -0,0 +1,178 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// maxEntries is how many keys are kept before the stale ones are removed
+const maxEntries = 10000
+
+// Throttle counts the failed attempts of each key and locks a key out once it
+// fails too many times in a row. Every extra failure doubles the lockout.
+// Attempts are reserved before they are checked, so concurrent attempts can't
+// go over the free attempts while none of them has failed yet
+type Throttle struct {
+	mu           sync.Mutex
+	entries      map[string]*entry
+	freeAttempts int
+	baseLockout  time.Duration
+	maxLockout   time.Duration
+	now          func() time.Time
+}
+
+type entry struct {
+	failures int
+	// pending are the reserved attempts that didn't fail nor were released yet
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// New creates a throttle that allows freeAttempts failures before locking a
+// key out for baseLockout, the lockout doubles up to maxLockout. The throttle
+// never locks a key out when freeAttempts isn't positive
+func New(freeAttempts int, baseLockout time.Duration, maxLockout time.Duration) *Throttle {
+	return &Throttle{
+		entries:      make(map[string]*entry),
+		freeAttempts: freeAttempts,
+		baseLockout:  baseLockout,
+		maxLockout:   maxLockout,
+		now:          time.Now,
+	}
+}
+
+// Reserve takes one of the free attempts of the key before the attempt is
+// checked, it must be followed by Fail or Release. When the key is locked out,
+// or the attempts in progress would use up its free attempts, it returns how
+// long the key must wait. Once a lockout ends the key gets one attempt at a
+// time, and the lockout only doubles when that attempt fails
+func (throttle *Throttle) Reserve(key string) (time.Duration, bool) {
+	if throttle.freeAttempts <= 0 {
+		return 0, true
+	}
+
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	now := throttle.now()
+	e := throttle.entry(key, now)
+
+	wait := e.lockedUntil.Sub(now)
+	if wait > 0 {
+		return wait, false
+	}
+
+	limit := throttle.freeAttempts
+	if e.failures >= limit {
+		limit = e.failures + 1
+	}
+	if e.failures+e.pending >= limit {
+		return throttle.lockout(e.failures + e.pending - throttle.freeAttempts), false
+	}
+
+	e.pending++
+	return 0, true
+}
+
+// Release ends a reserved attempt of the key that didn't fail
+func (throttle *Throttle) Release(key string) {
+	if throttle.freeAttempts <= 0 {
+		return
+	}
+
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	e, ok := throttle.entries[key]
+	if !ok {
+		return
+	}
+
+	if e.pending > 0 {
+		e.pending--
+	}
+	if e.pending == 0 && e.failures == 0 {
+		delete(throttle.entries, key)
+	}
+}
+
+// Fail records a failed attempt of the key, ending its reservation, and
+// returns how long the key is locked out because of it
+func (throttle *Throttle) Fail(key string) time.Duration {
+	if throttle.freeAttempts <= 0 {
+		return 0
+	}
+
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	now := throttle.now()
+	e := throttle.entry(key, now)
+
+	if e.pending > 0 {
+		e.pending--
+	}
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures < throttle.freeAttempts {
+		return 0
+	}
+
+	lockout := throttle.lockout(e.failures - throttle.freeAttempts)
+	e.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// entry returns the entry of the key, creating it when it doesn't exist or its
+// failures are old enough to be forgotten
+func (throttle *Throttle) entry(key string, now time.Time) *entry {
+	e, ok := throttle.entries[key]
+	if ok && !throttle.stale(e, now) {
+		return e
+	}
+
+	if len(throttle.entries) >= maxEntries {
+		throttle.prune(now)
+	}
+
+	e = &entry{}
+	throttle.entries[key] = e
+	return e
+}
+
+// Reset forgets the failed attempts of the key
+func (throttle *Throttle) Reset(key string) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	delete(throttle.entries, key)
+}
+
+// lockout returns baseLockout doubled n times without going over maxLockout
+func (throttle *Throttle) lockout(n int) time.Duration {
+	lockout := throttle.baseLockout
+	for i := 0; i < n && lockout < throttle.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > throttle.maxLockout {
+		lockout = throttle.maxLockout
+	}
+	return lockout
+}
+
+// stale reports whether the failures of an entry are old enough to be forgotten,
+// the entries with attempts in progress are never stale
+func (throttle *Throttle) stale(e *entry, now time.Time) bool {
+	return e.pending == 0 && now.After(e.lockedUntil) && now.Sub(e.lastFailure) > throttle.maxLockout
+}
+
+// prune removes the stale entries so keys that stop failing don't stay forever
+func (throttle *Throttle) prune(now time.Time) {
+	for key, e := range throttle.entries {
+		if throttle.stale(e, now) {
+			delete(throttle.entries, key)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package throttle
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestThrottle(freeAttempts int) (*Throttle, *time.Time) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	throttle := New(freeAttempts, time.Second, 8*time.Second)
+	throttle.now = func() time.Time { return now }
+	return throttle, &now
+}
+
+func TestThrottleLockout(t *testing.T) {
+	throttle, now := newTestThrottle(3)
+
+	for i := 0; i < 2; i++ {
+		_, ok := throttle.Reserve("pepito")
+		require.True(t, ok)
+		require.Zero(t, throttle.Fail("pepito"))
+	}
+
+	// the third failure locks the key out
+	_, ok := throttle.Reserve("pepito")
+	require.True(t, ok)
+	require.Equal(t, time.Second, throttle.Fail("pepito"))
+	wait, ok := throttle.Reserve("pepito")
+	require.False(t, ok)
+	require.Equal(t, time.Second, wait)
+
+	// other keys are not affected
+	_, ok = throttle.Reserve("nicolas")
+	require.True(t, ok)
+	throttle.Release("nicolas")
+
+	*now = now.Add(time.Second)
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+
+	// every extra failure doubles the lockout up to the maximum
+	require.Equal(t, 2*time.Second, throttle.Fail("pepito"))
+	require.Equal(t, 4*time.Second, throttle.Fail("pepito"))
+	require.Equal(t, 8*time.Second, throttle.Fail("pepito"))
+	require.Equal(t, 8*time.Second, throttle.Fail("pepito"))
+}
+
+func TestThrottleReset(t *testing.T) {
+	throttle, _ := newTestThrottle(1)
+
+	require.Equal(t, time.Second, throttle.Fail("pepito"))
+	_, ok := throttle.Reserve("pepito")
+	require.False(t, ok)
+
+	throttle.Reset("pepito")
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+	require.Equal(t, time.Second, throttle.Fail("pepito"))
+}
+
+func TestThrottleForgetsOldFailures(t *testing.T) {
+	throttle, now := newTestThrottle(2)
+
+	require.Zero(t, throttle.Fail("pepito"))
+
+	*now = now.Add(time.Minute)
+	require.Zero(t, throttle.Fail("pepito"))
+}
+
+func TestThrottleDisabled(t *testing.T) {
+	throttle, _ := newTestThrottle(0)
+
+	for i := 0; i < 10; i++ {
+		require.Zero(t, throttle.Fail("pepito"))
+	}
+	_, ok := throttle.Reserve("pepito")
+	require.True(t, ok)
+}
+
+func TestThrottlePrune(t *testing.T) {
+	throttle, now := newTestThrottle(5)
+
+	for i := 0; i < maxEntries; i++ {
+		throttle.Fail(fmt.Sprintf("user%d", i))
+	}
+	require.Len(t, throttle.entries, maxEntries)
+
+	*now = now.Add(time.Minute)
+	throttle.Fail("pepito")
+	require.Len(t, throttle.entries, 1)
+}
+
+func TestThrottleReserve(t *testing.T) {
+	throttle, _ := newTestThrottle(2)
+
+	_, ok := throttle.Reserve("pepito")
+	require.True(t, ok)
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+
+	// the attempts in progress could use up the free attempts
+	wait, ok := throttle.Reserve("pepito")
+	require.False(t, ok)
+	require.Equal(t, time.Second, wait)
+
+	throttle.Release("pepito")
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+
+	require.Zero(t, throttle.Fail("pepito"))
+	require.Equal(t, time.Second, throttle.Fail("pepito"))
+
+	_, ok = throttle.Reserve("pepito")
+	require.False(t, ok)
+
+	// released attempts don't leave entries behind
+	_, ok = throttle.Reserve("nicolas")
+	require.True(t, ok)
+	throttle.Release("nicolas")
+	require.NotContains(t, throttle.entries, "nicolas")
+}
+
+func TestThrottleConcurrentReserve(t *testing.T) {
+	throttle, _ := newTestThrottle(5)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := throttle.Reserve("pepito"); ok {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 5, reserved)
+	for i := 0; i < reserved; i++ {
+		throttle.Fail("pepito")
+	}
+
+	_, ok := throttle.Reserve("pepito")
+	require.False(t, ok)
+}
+
+func TestThrottleReserveAfterLockout(t *testing.T) {
+	throttle, now := newTestThrottle(3)
+
+	for i := 0; i < 3; i++ {
+		_, ok := throttle.Reserve("pepito")
+		require.True(t, ok)
+		throttle.Fail("pepito")
+	}
+
+	wait, ok := throttle.Reserve("pepito")
+	require.False(t, ok)
+	require.Equal(t, time.Second, wait)
+
+	// once the lockout ends one attempt at a time goes through
+	*now = now.Add(time.Second)
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+	wait, ok = throttle.Reserve("pepito")
+	require.False(t, ok)
+	require.Equal(t, 2*time.Second, wait)
+
+	// the lockout doubles when that attempt fails
+	require.Equal(t, 2*time.Second, throttle.Fail("pepito"))
+	wait, ok = throttle.Reserve("pepito")
+	require.False(t, ok)
+	require.Equal(t, 2*time.Second, wait)
+
+	// an attempt that doesn't fail lets the next one through
+	*now = now.Add(2 * time.Second)
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+	throttle.Release("pepito")
+	_, ok = throttle.Reserve("pepito")
+	require.True(t, ok)
+}
@@ -0,0 +1,210 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	minSecretKeySize = 32
+	minRSAKeyBits    = 2048
+)
+
+// JWTMaker is a JSON Web Token maker. It signs with HS256 when created from a
+// secret key, or with RS256 or EdDSA when created from a private key
+type JWTMaker struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	parser    *jwt.Parser
+	claims    Claims
+}
+
+// NewJWTMaker creates a new JWTMaker that signs with HS256
+func NewJWTMaker(secretKey string) (Maker, error) {
+	if len(secretKey) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+
+	key := []byte(secretKey)
+	return newJWTMaker(jwt.SigningMethodHS256, key, key), nil
+}
+
+// NewAsymmetricJWTMaker creates a new JWTMaker from a PEM encoded private key,
+// RSA keys sign with RS256 and Ed25519 keys sign with EdDSA
+func NewAsymmetricJWTMaker(privateKeyPEM []byte) (Maker, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("invalid key size: rsa keys must have at least %d bits", minRSAKeyBits)
+		}
+		return newJWTMaker(jwt.SigningMethodRS256, key, key.Public()), nil
+	case ed25519.PrivateKey:
+		return newJWTMaker(jwt.SigningMethodEdDSA, key, key.Public()), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+func newJWTMaker(method jwt.SigningMethod, signKey any, verifyKey any) *JWTMaker {
+	return &JWTMaker{
+		method:    method,
+		signKey:   signKey,
+		verifyKey: verifyKey,
+		// only the algorithm of the maker is accepted, so tokens with alg=none or
+		// signed with HS256 using the public key as secret are rejected. The
+		// claims are checked by Payload.Valid like the other makers
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{method.Alg()}),
+			jwt.WithoutClaimsValidation(),
+		),
+	}
+}
+
+// jwtClaims are the claims of the tokens, they use the registered claim names
+// so other services can read them with any JWT library. IssuedAtNanos keeps the
+// issue time with the precision of the other makers, the iat claim only has
+// seconds and the tokens issued in the same second as a password change would
+// look older than it
+type jwtClaims struct {
+	Role          string    `json:"role,omitempty"`
+	Type          TokenType `json:"token_type"`
+	Scopes        []string  `json:"scopes,omitempty"`
+	IssuedAtNanos int64     `json:"iat_nanos,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// CreateToken creates a new token for the specific username, role, duration and type.
+// JWT times have second precision, so the expiration of the payload is
+// truncated to match the one read back from the token
+func (maker *JWTMaker) CreateToken(username string, role string, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := maker.claims.newPayload(username, role, duration, tokenType)
+	if err != nil {
+		return "", nil, err
+	}
+	payload.ExpiresAt = payload.ExpiresAt.Truncate(time.Second)
+
+	token, err := jwt.NewWithClaims(maker.method, newJWTClaims(payload)).SignedString(maker.signKey)
+	return token, payload, err
+}
+
+// newJWTClaims maps a payload to the registered claims, the optional claims
+// are left out when they are empty
+func newJWTClaims(payload *Payload) jwtClaims {
+	claims := jwtClaims{
+		Role:          payload.Role,
+		Type:          payload.Type,
+		Scopes:        payload.Scopes,
+		IssuedAtNanos: payload.IssuedAt.UnixNano(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			Subject:   payload.Username,
+			Issuer:    payload.Issuer,
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiresAt),
+		},
+	}
+	if payload.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{payload.Audience}
+	}
+	if payload.NotBefore != nil {
+		claims.NotBefore = jwt.NewNumericDate(*payload.NotBefore)
+	}
+	return claims
+}
+
+// VerifyToken checks if the token is valid and has the given type
+func (maker *JWTMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	claims := &jwtClaims{}
+
+	_, err := maker.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if t.Method.Alg() != maker.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return maker.verifyKey, nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// the payload has a single audience, so tokens meant for several are rejected
+	id, err := uuid.Parse(claims.ID)
+	if err != nil || claims.IssuedAt == nil || claims.ExpiresAt == nil || len(claims.Audience) > 1 {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{
+		ID:        id,
+		Username:  claims.Subject,
+		Role:      claims.Role,
+		Type:      claims.Type,
+		Scopes:    claims.Scopes,
+		Issuer:    claims.Issuer,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if len(claims.Audience) == 1 {
+		payload.Audience = claims.Audience[0]
+	}
+	if claims.NotBefore != nil {
+		payload.NotBefore = &claims.NotBefore.Time
+	}
+	if claims.IssuedAtNanos != 0 {
+		issuedAt := time.Unix(0, claims.IssuedAtNanos)
+		if !issuedAt.Truncate(time.Second).Equal(claims.IssuedAt.Time) {
+			return nil, ErrInvalidToken
+		}
+		payload.IssuedAt = issuedAt
+	}
+
+	err = payload.Valid(tokenType, maker.claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// SetClaims implements ClaimsSetter, the claims are written to the registered
+// iss and aud claims of the JWTs
+func (maker *JWTMaker) SetClaims(claims Claims) {
+	maker.claims = claims
+}
+
+// parsePrivateKey reads a PKCS #8 or PKCS #1 private key from a PEM block
+func parsePrivateKey(privateKeyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("invalid private key: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,223 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPrivateKeyPEM(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func randomRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func randomEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestJWTMaker(t *testing.T) {
+	hsMaker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+
+	rsMaker, err := NewAsymmetricJWTMaker(randomPrivateKeyPEM(t, randomRSAKey(t)))
+	require.NoError(t, err)
+
+	edMaker, err := NewAsymmetricJWTMaker(randomPrivateKeyPEM(t, randomEd25519Key(t)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		maker Maker
+		alg   string
+	}{
+		{name: "HS256", maker: hsMaker, alg: "HS256"},
+		{name: "RS256", maker: rsMaker, alg: "RS256"},
+		{name: "EdDSA", maker: edMaker, alg: "EdDSA"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			username := util.RandomUsername()
+			role := util.AdminRole
+			duration := time.Minute
+
+			issuedAt := time.Now()
+			expiredAt := issuedAt.Add(duration)
+
+			token, payload, err := tc.maker.CreateToken(username, role, duration, TokenTypeAccessToken)
+			require.NoError(t, err)
+			require.NotEmpty(t, token)
+			require.NotEmpty(t, payload)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwtClaims{})
+			require.NoError(t, err)
+			require.Equal(t, tc.alg, parsed.Method.Alg())
+
+			gotPayload, err := tc.maker.VerifyToken(token, TokenTypeAccessToken)
+			require.NoError(t, err)
+			require.Equal(t, payload.ID, gotPayload.ID)
+			require.Equal(t, username, gotPayload.Username)
+			require.Equal(t, role, gotPayload.Role)
+			require.Equal(t, TokenTypeAccessToken, gotPayload.Type)
+			require.True(t, payload.IssuedAt.Equal(gotPayload.IssuedAt))
+			require.True(t, payload.ExpiresAt.Equal(gotPayload.ExpiresAt))
+			require.WithinDuration(t, issuedAt, gotPayload.IssuedAt, time.Second)
+			require.WithinDuration(t, expiredAt, gotPayload.ExpiresAt, time.Second)
+		})
+	}
+}
+
+func TestExpiredJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+
+	token, payload, err := maker.CreateToken(util.RandomUsername(), util.UserRole, -time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token, TokenTypeAccessToken)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTTokenAlgNone(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+
+	claims := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   util.RandomUsername(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTTokenAlgConfusion(t *testing.T) {
+	key := randomRSAKey(t)
+	maker, err := NewAsymmetricJWTMaker(randomPrivateKeyPEM(t, key))
+	require.NoError(t, err)
+
+	// a token signed with HS256 using the public key as the secret must not pass
+	// as a RS256 token
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	claims := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   util.RandomUsername(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicKeyPEM)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+
+	otherMaker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+
+	token, _, err := otherMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	for _, token := range []string{"Invalid token", token} {
+		payload, err := maker.VerifyToken(token, TokenTypeAccessToken)
+		require.Error(t, err)
+		require.EqualError(t, err, ErrInvalidToken.Error())
+		require.Nil(t, payload)
+	}
+}
+
+func TestNewJWTMakerInvalidKey(t *testing.T) {
+	_, err := NewJWTMaker(util.RandomPassword(31))
+	require.Error(t, err)
+
+	_, err = NewAsymmetricJWTMaker([]byte("not a pem"))
+	require.Error(t, err)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = NewAsymmetricJWTMaker(randomPrivateKeyPEM(t, smallKey))
+	require.Error(t, err)
+}
+
+func TestJWTOptionalClaims(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomPassword(32))
+	require.NoError(t, err)
+	jwtMaker := maker.(*JWTMaker)
+
+	claims := Claims{Issuer: "devoratasks", Audience: "devoratasks-api"}
+	jwtMaker.SetClaims(claims)
+
+	payload, err := claims.newPayload(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	payload.NotBefore = &notBefore
+	payload.Scopes = []string{"tasks:read"}
+
+	token, err := jwt.NewWithClaims(jwtMaker.method, newJWTClaims(payload)).SignedString(jwtMaker.signKey)
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwtClaims{})
+	require.NoError(t, err)
+	require.Equal(t, jwt.ClaimStrings{claims.Audience}, parsed.Claims.(*jwtClaims).Audience)
+
+	gotPayload, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, payload.Scopes, gotPayload.Scopes)
+	require.Equal(t, claims.Issuer, gotPayload.Issuer)
+	require.Equal(t, claims.Audience, gotPayload.Audience)
+	require.NotNil(t, gotPayload.NotBefore)
+	require.True(t, notBefore.Equal(*gotPayload.NotBefore))
+
+	// a token issued for another audience must not pass
+	payload.Audience = "another-api"
+	token, err = jwt.NewWithClaims(jwtMaker.method, newJWTClaims(payload)).SignedString(jwtMaker.signKey)
+	require.NoError(t, err)
+
+	gotPayload, err = maker.VerifyToken(token, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, gotPayload)
+}
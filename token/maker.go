@@ -4,9 +4,17 @@ import "time"
 
 // Maker is an interface for managing tokens
 type Maker interface {
-	// CreateToken creates a new token for the specific username and duration
-	CreateToken(username string, duration time.Duration) (string, error)
+	// CreateToken creates a new token for the specific username, role, duration and type
+	CreateToken(username string, role string, duration time.Duration, tokenType TokenType) (string, *Payload, error)
 
-	// VerifyToken checks if the token is valid or not
-	VerifyToken(token string) (*Payload, error)
+	// VerifyToken checks if the token is valid and has the given type
+	VerifyToken(token string, tokenType TokenType) (*Payload, error)
+}
+
+// ClaimsSetter is implemented by every maker
+type ClaimsSetter interface {
+	// SetClaims sets the issuer and audience of the tokens the maker creates,
+	// the tokens it verifies must have the same ones. It isn't safe to call
+	// concurrently with the maker, so it must be called before the maker is used
+	SetClaims(claims Claims)
 }
@@ -2,6 +2,7 @@ package token
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aead/chacha20poly1305"
@@ -9,50 +10,153 @@ import (
 	"github.com/o1egl/paseto"
 )
 
+// SymmetricKey is a key of the PasetoMaker key ring. ExpiresAt is only set on
+// retired keys, they stop verifying tokens once it passes
+type SymmetricKey struct {
+	ID        string
+	Key       string
+	ExpiresAt time.Time
+}
+
 // PasetoMaker is a Paseto maker
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetrickey []byte
+	paseto *paseto.V2
+	// active signs the new tokens, keys verifies them by the key id in their footer
+	active SymmetricKey
+	keys   map[string]SymmetricKey
+	claims Claims
 }
 
 // NewPasetoMaker crates a new PasetoMaker
 func NewPasetoMaker(symmetrickey string) (Maker, error) {
-	if len(symmetrickey) != chacha20poly1305.KeySize {
-		err := fmt.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
-		return nil, err
-	}
+	return NewPasetoKeyRingMaker(SymmetricKey{Key: symmetrickey})
+}
+
+// NewPasetoKeyRingMaker creates a new PasetoMaker that signs with the active
+// key and still verifies the tokens signed with the retired keys until they
+// expire. The tokens of a key without id have no key id in their footer, like
+// the ones created before the key ring existed
+func NewPasetoKeyRingMaker(active SymmetricKey, retired ...SymmetricKey) (Maker, error) {
+	active.ExpiresAt = time.Time{}
 
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetrickey: []byte(symmetrickey),
+		paseto: paseto.NewV2(),
+		active: active,
+		keys:   make(map[string]SymmetricKey, len(retired)+1),
+	}
+
+	for _, key := range append([]SymmetricKey{active}, retired...) {
+		if len(key.Key) != chacha20poly1305.KeySize {
+			err := fmt.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
+			return nil, err
+		}
+
+		if _, ok := maker.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicated key id %q", key.ID)
+		}
+		maker.keys[key.ID] = key
 	}
 
 	return maker, nil
 }
 
-// CreateToken creates a new token for the specific username and duration
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, duration)
+// ParseSymmetricKeys reads a comma separated list of retired keys, each one
+// written as id:key:expiration with the expiration in RFC 3339 format. The id
+// is empty for a key that signed its tokens without key id. The id can't
+// contain ':' but the key can, since the expiration is read from the end.
+// Neither of them can contain ',', which separates the keys
+func ParseSymmetricKeys(s string) ([]SymmetricKey, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	entries := strings.Split(s, ",")
+	keys := make([]SymmetricKey, len(entries))
+	for i, entry := range entries {
+		id, rest, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key %d: must be id:key:expiration", i+1)
+		}
+
+		key, expiresAt, err := cutExpiration(rest)
+		if err != nil && i < len(entries)-1 {
+			// a key with ',' is split in an entry without expiration and the rest
+			return nil, fmt.Errorf("invalid key %d: %w, keys can't contain ','", i+1, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d: %w", i+1, err)
+		}
+
+		keys[i] = SymmetricKey{
+			ID:        id,
+			Key:       key,
+			ExpiresAt: expiresAt,
+		}
+	}
+
+	return keys, nil
+}
+
+// cutExpiration splits key:expiration at the last ':' followed by a valid
+// RFC 3339 time. The time has colons of its own, so the ':' before it is
+// searched from the end instead of taking the last one
+func cutExpiration(s string) (string, time.Time, error) {
+	for i := strings.LastIndex(s, ":"); i >= 0; i = strings.LastIndex(s[:i], ":") {
+		expiresAt, err := time.Parse(time.RFC3339, s[i+1:])
+		if err == nil {
+			return s[:i], expiresAt, nil
+		}
+	}
+
+	return "", time.Time{}, fmt.Errorf("must be id:key:expiration with an RFC 3339 expiration")
+}
+
+// CreateToken creates a new token for the specific username, role, duration and type
+func (maker *PasetoMaker) CreateToken(username string, role string, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := maker.claims.newPayload(username, role, duration, tokenType)
 	if err != nil {
-		return "", nil
+		return "", nil, err
+	}
+
+	var footer any
+	if maker.active.ID != "" {
+		footer = keyFooter{KeyID: maker.active.ID}
 	}
 
-	return maker.paseto.Encrypt(maker.symmetrickey, payload, nil)
+	token, err := maker.paseto.Encrypt([]byte(maker.active.Key), payload, footer)
+	return token, payload, err
 }
 
-// VerifyToken checks if the token is valid or not
-func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+// VerifyToken checks if the token is valid and has the given type
+func (maker *PasetoMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	var footer keyFooter
+	err := paseto.ParseFooter(token, &footer)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := maker.keys[footer.KeyID]
+	if !ok || (!key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt)) {
+		return nil, ErrInvalidToken
+	}
+
 	payload := &Payload{}
 
-	err := maker.paseto.Decrypt(token, maker.symmetrickey, payload, nil)
+	err = maker.paseto.Decrypt(token, []byte(key.Key), payload, nil)
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	err = payload.Valid()
+	err = payload.Valid(tokenType, maker.claims)
 	if err != nil {
 		return nil, err
 	}
 
 	return payload, nil
 }
+
+// SetClaims implements ClaimsSetter, the claims are checked whichever key of
+// the ring encrypted the token
+func (maker *PasetoMaker) SetClaims(claims Claims) {
+	maker.claims = claims
+}
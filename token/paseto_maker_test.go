@@ -1,10 +1,14 @@
 package token
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/o1egl/paseto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,21 +17,24 @@ func TestPasetoMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomUsername()
+	role := util.AdminRole
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(username, role, duration, TokenTypeAccessToken)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
 
-	payload, err := maker.VerifyToken(token)
+	payload, err = maker.VerifyToken(token, TokenTypeAccessToken)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiresAt, time.Second)
 }
@@ -36,11 +43,12 @@ func TestExpiredPasetoToken(t *testing.T) {
 	maker, err := NewPasetoMaker(util.RandomPassword(32))
 	require.NoError(t, err)
 
-	token, err := maker.CreateToken(util.RandomUsername(), -time.Minute)
+	token, payload, err := maker.CreateToken(util.RandomUsername(), util.UserRole, -time.Minute, TokenTypeAccessToken)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
 
-	payload, err := maker.VerifyToken(token)
+	payload, err = maker.VerifyToken(token, TokenTypeAccessToken)
 	require.Error(t, err)
 	require.EqualError(t, err, ErrExpiredToken.Error())
 	require.Nil(t, payload)
@@ -50,8 +58,155 @@ func TestInvalidPasetoToken(t *testing.T) {
 	maker, err := NewPasetoMaker(util.RandomPassword(32))
 	require.NoError(t, err)
 
-	payload, err := maker.VerifyToken("Invalid token")
+	payload, err := maker.VerifyToken("Invalid token", TokenTypeAccessToken)
 	require.Error(t, err)
 	require.EqualError(t, err, ErrInvalidToken.Error())
 	require.Nil(t, payload)
 }
+
+func TestPasetoMakerKeyRotation(t *testing.T) {
+	oldKey := SymmetricKey{ID: "2024-01", Key: util.RandomPassword(32)}
+	newKey := SymmetricKey{ID: "2024-02", Key: util.RandomPassword(32)}
+
+	oldMaker, err := NewPasetoKeyRingMaker(oldKey)
+	require.NoError(t, err)
+
+	oldToken, oldPayload, err := oldMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	// the old key is retired until the tokens it signed expire
+	retiredKey := oldKey
+	retiredKey.ExpiresAt = time.Now().Add(time.Hour)
+	newMaker, err := NewPasetoKeyRingMaker(newKey, retiredKey)
+	require.NoError(t, err)
+
+	newToken, newPayload, err := newMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	payload, err := newMaker.VerifyToken(oldToken, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, oldPayload.ID, payload.ID)
+
+	payload, err = newMaker.VerifyToken(newToken, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, newPayload.ID, payload.ID)
+
+	// servers that weren't rotated yet don't know the new key
+	payload, err = oldMaker.VerifyToken(newToken, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	// once the retired key expires its tokens stop working
+	retiredKey.ExpiresAt = time.Now().Add(-time.Second)
+	rotatedMaker, err := NewPasetoKeyRingMaker(newKey, retiredKey)
+	require.NoError(t, err)
+
+	payload, err = rotatedMaker.VerifyToken(oldToken, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	payload, err = rotatedMaker.VerifyToken(newToken, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, newPayload.ID, payload.ID)
+}
+
+func TestPasetoMakerKeyIDFooter(t *testing.T) {
+	key := SymmetricKey{ID: "2024-01", Key: util.RandomPassword(32)}
+	otherKey := SymmetricKey{ID: "2024-02", Key: util.RandomPassword(32)}
+
+	maker, err := NewPasetoKeyRingMaker(key, SymmetricKey{
+		ID:        otherKey.ID,
+		Key:       otherKey.Key,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	var footer keyFooter
+	err = paseto.ParseFooter(token, &footer)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, footer.KeyID)
+
+	// the footer is authenticated, pointing it to another key breaks the token
+	parts := strings.Split(token, ".")
+	parts[3] = base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"` + otherKey.ID + `"}`))
+
+	payload, err := maker.VerifyToken(strings.Join(parts, "."), TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	parts[3] = base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"unknown"}`))
+
+	payload, err = maker.VerifyToken(strings.Join(parts, "."), TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestPasetoMakerLegacyKey(t *testing.T) {
+	legacyKey := util.RandomPassword(32)
+
+	// tokens created before the key ring have no key id
+	legacyMaker, err := NewPasetoMaker(legacyKey)
+	require.NoError(t, err)
+
+	legacyToken, legacyPayload, err := legacyMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoKeyRingMaker(
+		SymmetricKey{ID: "2024-01", Key: util.RandomPassword(32)},
+		SymmetricKey{Key: legacyKey, ExpiresAt: time.Now().Add(time.Hour)},
+	)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(legacyToken, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, legacyPayload.ID, payload.ID)
+}
+
+func TestNewPasetoKeyRingMakerInvalidKeys(t *testing.T) {
+	key := SymmetricKey{ID: "2024-01", Key: util.RandomPassword(32)}
+
+	_, err := NewPasetoKeyRingMaker(key, SymmetricKey{ID: "2024-02", Key: util.RandomPassword(31)})
+	require.Error(t, err)
+
+	_, err = NewPasetoKeyRingMaker(key, SymmetricKey{ID: key.ID, Key: util.RandomPassword(32)})
+	require.Error(t, err)
+}
+
+func TestParseSymmetricKeys(t *testing.T) {
+	key1 := util.RandomPassword(32)
+	key2 := util.RandomPassword(32)
+	expiresAt := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	keys, err := ParseSymmetricKeys(fmt.Sprintf("2024-01:%s:2024-02-01T00:00:00Z, :%s:2024-02-01T00:00:00Z", key1, key2))
+	require.NoError(t, err)
+	require.Equal(t, []SymmetricKey{
+		{ID: "2024-01", Key: key1, ExpiresAt: expiresAt},
+		{ID: "", Key: key2, ExpiresAt: expiresAt},
+	}, keys)
+
+	keys, err = ParseSymmetricKeys("")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	_, err = ParseSymmetricKeys("2024-01:" + key1)
+	require.Error(t, err)
+
+	_, err = ParseSymmetricKeys("2024-01:" + key1 + ":tomorrow")
+	require.Error(t, err)
+
+	// the key can contain ':', the expiration is read from the end
+	colonKey := "abc:def:" + util.RandomPassword(24)
+	keys, err = ParseSymmetricKeys(fmt.Sprintf("2024-03:%s:2024-02-01T00:00:00+02:00", colonKey))
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, "2024-03", keys[0].ID)
+	require.Equal(t, colonKey, keys[0].Key)
+	require.True(t, expiresAt.Add(-2*time.Hour).Equal(keys[0].ExpiresAt))
+
+	// but it can't contain ',', which separates the keys
+	_, err = ParseSymmetricKeys(fmt.Sprintf("2024-03:abc,%s:2024-02-01T00:00:00Z", key1))
+	require.ErrorContains(t, err, "keys can't contain ','")
+}
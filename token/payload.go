@@ -2,6 +2,7 @@ package token
 
 import (
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,16 +13,39 @@ var (
 	ErrExpiredToken = errors.New("token has expired")
 )
 
-// Payload contains the payload data of the token
+// TokenType tells access tokens apart from refresh tokens, so a refresh token
+// can't be used to call the api and an access token can't renew a session
+type TokenType string
+
+const (
+	TokenTypeAccessToken  TokenType = "access"
+	TokenTypeRefreshToken TokenType = "refresh"
+)
+
+// Payload contains the payload data of the token. Scopes, Issuer, Audience and
+// NotBefore are optional, a token without scopes can use every route
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	IssuedAt  time.Time `json:"issued_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID        uuid.UUID  `json:"id"`
+	Username  string     `json:"username"`
+	Role      string     `json:"role"`
+	Type      TokenType  `json:"token_type"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	Audience  string     `json:"audience,omitempty"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// Claims are the issuer and audience a maker puts in its tokens, the tokens it
+// verifies must have the same ones. Empty claims are neither set nor checked
+type Claims struct {
+	Issuer   string
+	Audience string
 }
 
-// NewPayload creates a new token payload with a specific username and duration
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+// NewPayload creates a new token payload with a specific username, role, duration and type
+func NewPayload(username string, role string, duration time.Duration, tokenType TokenType) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -30,6 +54,8 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		ID:        tokenID,
 		Username:  username,
+		Role:      role,
+		Type:      tokenType,
 		IssuedAt:  time.Now(),
 		ExpiresAt: time.Now().Add(duration),
 	}
@@ -37,8 +63,40 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	return payload, nil
 }
 
-func (payload *Payload) Valid() error {
-	if time.Now().After(payload.ExpiresAt) {
+// newPayload creates a new token payload with the claims of a maker
+func (claims Claims) newPayload(username string, role string, duration time.Duration, tokenType TokenType) (*Payload, error) {
+	payload, err := NewPayload(username, role, duration, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Issuer = claims.Issuer
+	payload.Audience = claims.Audience
+	return payload, nil
+}
+
+// Valid checks that the token has the expected type, that it was issued by and
+// for the expected claims, that its scopes aren't empty and that the current
+// time is between its not before and its expiration
+func (payload *Payload) Valid(tokenType TokenType, expected Claims) error {
+	if payload.Type != tokenType {
+		return ErrInvalidToken
+	}
+	if expected.Issuer != "" && payload.Issuer != expected.Issuer {
+		return ErrInvalidToken
+	}
+	if expected.Audience != "" && payload.Audience != expected.Audience {
+		return ErrInvalidToken
+	}
+	if slices.Contains(payload.Scopes, "") {
+		return ErrInvalidToken
+	}
+
+	now := time.Now()
+	if payload.NotBefore != nil && now.Before(*payload.NotBefore) {
+		return ErrInvalidToken
+	}
+	if now.After(payload.ExpiresAt) {
 		return ErrExpiredToken
 	}
 	return nil
@@ -0,0 +1,114 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadValid(t *testing.T) {
+	claims := Claims{Issuer: "devoratasks", Audience: "devoratasks-api"}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	testCases := []struct {
+		name     string
+		update   func(payload *Payload)
+		expected Claims
+		err      error
+	}{
+		{
+			name:     "OK",
+			update:   func(payload *Payload) {},
+			expected: claims,
+		},
+		{
+			name: "NoExpectedClaims",
+			update: func(payload *Payload) {
+				payload.Issuer = ""
+				payload.Audience = ""
+			},
+		},
+		{
+			name: "Scopes",
+			update: func(payload *Payload) {
+				payload.Scopes = []string{"tasks:read"}
+			},
+			expected: claims,
+		},
+		{
+			name: "WrongType",
+			update: func(payload *Payload) {
+				payload.Type = TokenTypeRefreshToken
+			},
+			expected: claims,
+			err:      ErrInvalidToken,
+		},
+		{
+			name: "EmptyScope",
+			update: func(payload *Payload) {
+				payload.Scopes = []string{"tasks:read", ""}
+			},
+			expected: claims,
+			err:      ErrInvalidToken,
+		},
+		{
+			name: "WrongIssuer",
+			update: func(payload *Payload) {
+				payload.Issuer = "someone-else"
+			},
+			expected: claims,
+			err:      ErrInvalidToken,
+		},
+		{
+			name: "MissingAudience",
+			update: func(payload *Payload) {
+				payload.Audience = ""
+			},
+			expected: claims,
+			err:      ErrInvalidToken,
+		},
+		{
+			name: "NotBeforePassed",
+			update: func(payload *Payload) {
+				payload.NotBefore = &past
+			},
+			expected: claims,
+		},
+		{
+			name: "NotBeforeInFuture",
+			update: func(payload *Payload) {
+				payload.NotBefore = &future
+			},
+			expected: claims,
+			err:      ErrInvalidToken,
+		},
+		{
+			name: "Expired",
+			update: func(payload *Payload) {
+				payload.ExpiresAt = past
+			},
+			expected: claims,
+			err:      ErrExpiredToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := claims.newPayload(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+			require.NoError(t, err)
+			require.Equal(t, claims.Issuer, payload.Issuer)
+			require.Equal(t, claims.Audience, payload.Audience)
+
+			tc.update(payload)
+			err = payload.Valid(TokenTypeAccessToken, tc.expected)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// publicPasetoHeader is the header of PASETO v4.public tokens
+const publicPasetoHeader = "v4.public."
+
+// PublicKey is a public key that verifies tokens, identified by its key id
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
+
+// KeyPublisher is implemented by the makers whose tokens can be verified
+// with public keys, so other services don't need the signing key
+type KeyPublisher interface {
+	// PublicKeys returns the keys that verify the tokens of the maker
+	PublicKeys() []PublicKey
+}
+
+// PublicPasetoMaker is a PASETO v4.public maker, the tokens are signed with
+// an Ed25519 private key and carry the id of the key in the footer
+type PublicPasetoMaker struct {
+	privateKey ed25519.PrivateKey
+	publicKey  PublicKey
+	claims     Claims
+}
+
+// NewPublicPasetoMaker creates a new PublicPasetoMaker from a PEM encoded
+// Ed25519 private key
+func NewPublicPasetoMaker(privateKeyPEM []byte) (Maker, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T: must be ed25519", privateKey)
+	}
+
+	publicKey := key.Public().(ed25519.PublicKey)
+	maker := &PublicPasetoMaker{
+		privateKey: key,
+		publicKey: PublicKey{
+			ID:  KeyID(publicKey),
+			Key: publicKey,
+		},
+	}
+
+	return maker, nil
+}
+
+// KeyID returns the id of a public key, it's derived from the key so every
+// server signing with the same key publishes the same id
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// keyFooter is the footer of the tokens, it names the key that verifies them
+type keyFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// CreateToken creates a new token for the specific username, role, duration and type
+func (maker *PublicPasetoMaker) CreateToken(username string, role string, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := maker.claims.newPayload(username, role, duration, tokenType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	footer, err := json.Marshal(keyFooter{KeyID: maker.publicKey.ID})
+	if err != nil {
+		return "", nil, err
+	}
+
+	signature := ed25519.Sign(maker.privateKey, preAuthEncode([]byte(publicPasetoHeader), message, footer, nil))
+
+	token := publicPasetoHeader +
+		base64.RawURLEncoding.EncodeToString(append(message, signature...)) + "." +
+		base64.RawURLEncoding.EncodeToString(footer)
+	return token, payload, nil
+}
+
+// VerifyToken checks if the token is valid and has the given type
+func (maker *PublicPasetoMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	return VerifyPublicToken(token, tokenType, maker.PublicKeys(), maker.claims)
+}
+
+// SetClaims implements ClaimsSetter, the services that verify the tokens with
+// the public key must require the same claims
+func (maker *PublicPasetoMaker) SetClaims(claims Claims) {
+	maker.claims = claims
+}
+
+// PublicKeys returns the key that verifies the tokens of the maker
+func (maker *PublicPasetoMaker) PublicKeys() []PublicKey {
+	return []PublicKey{maker.publicKey}
+}
+
+// VerifyPublicToken checks a PASETO v4.public token with the key named in its
+// footer, its type and the expected claims, tokens signed with any other key
+// are invalid
+func VerifyPublicToken(token string, tokenType TokenType, keys []PublicKey, expected Claims) (*Payload, error) {
+	message, footer, err := splitPublicToken(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var tokenFooter keyFooter
+	err = json.Unmarshal(footer, &tokenFooter)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var publicKey ed25519.PublicKey
+	for _, key := range keys {
+		if key.ID == tokenFooter.KeyID {
+			publicKey = key.Key
+			break
+		}
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidToken
+	}
+
+	body, signature := message[:len(message)-ed25519.SignatureSize], message[len(message)-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, preAuthEncode([]byte(publicPasetoHeader), body, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	err = json.Unmarshal(body, payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid(tokenType, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// splitPublicToken decodes the signed message and the footer of a token
+func splitPublicToken(token string) (message []byte, footer []byte, err error) {
+	if !strings.HasPrefix(token, publicPasetoHeader) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, publicPasetoHeader), ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrInvalidToken
+	}
+
+	message, err = base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(message) < ed25519.SignatureSize {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if len(parts) == 2 {
+		footer, err = base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, nil, ErrInvalidToken
+		}
+	}
+
+	return message, footer, nil
+}
+
+// preAuthEncode is the PASETO pre-authentication encoding, it joins the pieces
+// prefixed by their lengths so they can't be shifted from one piece to another
+func preAuthEncode(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+
+	le64 := func(n int) {
+		var b [8]byte
+		// the most significant bit must be cleared for interoperability
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&^(1<<63))
+		buf.Write(b[:])
+	}
+
+	le64(len(pieces))
+	for _, piece := range pieces {
+		le64(len(piece))
+		buf.Write(piece)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,135 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicPasetoMaker(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomPrivateKeyPEM(t, randomEd25519Key(t)))
+	require.NoError(t, err)
+
+	username := util.RandomUsername()
+	role := util.AdminRole
+	duration := time.Minute
+
+	issuedAt := time.Now()
+	expiredAt := issuedAt.Add(duration)
+
+	token, payload, err := maker.CreateToken(username, role, duration, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+	require.True(t, strings.HasPrefix(token, publicPasetoHeader))
+
+	payload, err = maker.VerifyToken(token, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	require.NotZero(t, payload.ID)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
+	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiresAt, time.Second)
+
+	keys := maker.(KeyPublisher).PublicKeys()
+	require.Len(t, keys, 1)
+	require.Equal(t, KeyID(keys[0].Key), keys[0].ID)
+	require.Contains(t, token, base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"`+keys[0].ID+`"}`)))
+}
+
+func TestExpiredPublicPasetoToken(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomPrivateKeyPEM(t, randomEd25519Key(t)))
+	require.NoError(t, err)
+
+	token, payload, err := maker.CreateToken(util.RandomUsername(), util.UserRole, -time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token, TokenTypeAccessToken)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidPublicPasetoToken(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomPrivateKeyPEM(t, randomEd25519Key(t)))
+	require.NoError(t, err)
+
+	otherMaker, err := NewPublicPasetoMaker(randomPrivateKeyPEM(t, randomEd25519Key(t)))
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	otherToken, _, err := otherMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	body, footer, _ := strings.Cut(strings.TrimPrefix(token, publicPasetoHeader), ".")
+	_, otherFooter, _ := strings.Cut(strings.TrimPrefix(otherToken, publicPasetoHeader), ".")
+
+	message, err := base64.RawURLEncoding.DecodeString(body)
+	require.NoError(t, err)
+	message[0] ^= 1
+	tampered := publicPasetoHeader + base64.RawURLEncoding.EncodeToString(message) + "." + footer
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Malformed", token: "Invalid token"},
+		{name: "OtherVersion", token: "v2.public." + body + "." + footer},
+		{name: "NoFooter", token: publicPasetoHeader + body},
+		{name: "UnknownKey", token: otherToken},
+		{name: "SwappedFooter", token: publicPasetoHeader + body + "." + otherFooter},
+		{name: "Tampered", token: tampered},
+		{name: "TooShort", token: publicPasetoHeader + "AAAA." + footer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := maker.VerifyToken(tc.token, TokenTypeAccessToken)
+			require.Error(t, err)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			require.Nil(t, payload)
+		})
+	}
+}
+
+func TestNewPublicPasetoMakerInvalidKey(t *testing.T) {
+	_, err := NewPublicPasetoMaker(randomPrivateKeyPEM(t, randomRSAKey(t)))
+	require.Error(t, err)
+
+	_, err = NewPublicPasetoMaker([]byte("not a pem"))
+	require.Error(t, err)
+}
+
+func TestPreAuthEncode(t *testing.T) {
+	require.Equal(t, "0000000000000000", hex.EncodeToString(preAuthEncode()))
+	require.Equal(t, "01000000000000000000000000000000", hex.EncodeToString(preAuthEncode([]byte{})))
+	require.Equal(t, "0100000000000000040000000000000074657374", hex.EncodeToString(preAuthEncode([]byte("test"))))
+}
+
+// TestPublicPasetoSignature checks the signature against the 4-S-1 test vector
+// of the PASETO specification
+func TestPublicPasetoSignature(t *testing.T) {
+	seed, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774")
+	require.NoError(t, err)
+	privateKey := ed25519.NewKeyFromSeed(seed)
+
+	message := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	signature := ed25519.Sign(privateKey, preAuthEncode([]byte(publicPasetoHeader), message, nil, nil))
+	token := publicPasetoHeader + base64.RawURLEncoding.EncodeToString(append(message, signature...))
+
+	expected := "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9" +
+		"bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+	require.Equal(t, expected, token)
+}
@@ -9,11 +9,42 @@ import (
 // Config sotores all the configuration of the application.
 // The values are read by viper from a config file or environment variables.
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBDriver              string        `mapstructure:"DB_DRIVER"`
+	DBSource              string        `mapstructure:"DB_SOURCE"`
+	DBTimeout             time.Duration `mapstructure:"DB_TIMEOUT"`
+	ServerAddress         string        `mapstructure:"SERVER_ADDRESS"`
+	AppURL                string        `mapstructure:"APP_URL"`
+	TokenType             string        `mapstructure:"TOKEN_TYPE"`
+	TokenSymmetricKey     string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenKeyID            string        `mapstructure:"TOKEN_KEY_ID"`
+	TokenRetiredKeys      string        `mapstructure:"TOKEN_RETIRED_KEYS"`
+	TokenPrivateKeyFile   string        `mapstructure:"TOKEN_PRIVATE_KEY_FILE"`
+	TokenIssuer           string        `mapstructure:"TOKEN_ISSUER"`
+	TokenAudience         string        `mapstructure:"TOKEN_AUDIENCE"`
+	AccessTokenDuration   time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration  time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	LoginMaxAttempts      int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginMaxAttemptsPerIP int           `mapstructure:"LOGIN_MAX_ATTEMPTS_PER_IP"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginMaxLockout       time.Duration `mapstructure:"LOGIN_MAX_LOCKOUT"`
+	MFAIssuer             string        `mapstructure:"MFA_ISSUER"`
+	MFAChallengeDuration  time.Duration `mapstructure:"MFA_CHALLENGE_DURATION"`
+	DenylistCacheSize     int           `mapstructure:"DENYLIST_CACHE_SIZE"`
+	DenylistCacheTTL      time.Duration `mapstructure:"DENYLIST_CACHE_TTL"`
+	DenylistCleanupEvery  time.Duration `mapstructure:"DENYLIST_CLEANUP_EVERY"`
+	PasswordResetDuration time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
+	PasswordResetMax      int           `mapstructure:"PASSWORD_RESET_MAX"`
+	PasswordResetMaxPerIP int           `mapstructure:"PASSWORD_RESET_MAX_PER_IP"`
+	VerifyEmailDuration   time.Duration `mapstructure:"VERIFY_EMAIL_DURATION"`
+	RequireVerifiedEmail  bool          `mapstructure:"REQUIRE_VERIFIED_EMAIL"`
+	Mailer                string        `mapstructure:"MAILER"`
+	MailLogFile           string        `mapstructure:"MAIL_LOG_FILE"`
+	MailTimeout           time.Duration `mapstructure:"MAIL_TIMEOUT"`
+	SMTPHost              string        `mapstructure:"SMTP_HOST"`
+	SMTPPort              int           `mapstructure:"SMTP_PORT"`
+	SMTPUsername          string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword          string        `mapstructure:"SMTP_PASSWORD"`
+	EmailSenderAddress    string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
 }
 
 // LoadConfig reads configuration from config file or environment variables.
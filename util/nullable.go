@@ -0,0 +1,34 @@
+package util
+
+import "encoding/json"
+
+// Nullable is an optional JSON field that tells apart a field the client didn't
+// send from a field sent as null, so updates can keep the stored value of the
+// missing fields and clear the null ones
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+	return nil
+}
+
+// Or returns the value sent by the client, or stored when the field was missing
+func (n Nullable[T]) Or(stored *T) *T {
+	if !n.Set {
+		return stored
+	}
+	return n.Value
+}
@@ -15,7 +15,7 @@ import (
 )
 
 var avalaibleRequirements = []string{
-	"required", "email", "min", "max",
+	"required", "email", "min", "max", "oneof",
 }
 
 type requirement struct {
@@ -37,6 +37,8 @@ func (r requirement) Verify() error {
 		err = r.Min()
 	case "max":
 		err = r.Max()
+	case "oneof":
+		err = r.OneOf()
 	}
 	return err
 }
@@ -120,6 +122,20 @@ func (r requirement) Email() (err error) {
 	return err
 }
 
+// OneOf checks that the value is one of the space separated options,
+// empty values are left to the required requirement
+func (r requirement) OneOf() (err error) {
+	if r.fieldValue == "" {
+		return nil
+	}
+
+	options := strings.Fields(r.reqValue)
+	if !slices.Contains(options, r.fieldValue) {
+		err = fmt.Errorf("Requirements error: '%s' should be one of [%s]", r.fieldValue, r.reqValue)
+	}
+	return err
+}
+
 func GetRequirements(obj any) []requirement {
 	t := reflect.TypeOf(obj)
 	v := reflect.ValueOf(obj)
@@ -131,17 +147,27 @@ func GetRequirements(obj any) []requirement {
 		fieldType := t.Field(i).Type.String()
 		fieldValue := ""
 
+		// pointer fields are optional, they are only verified when they are set
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+			fieldType = value.Type().String()
+		}
+
 		switch fieldType {
 		case "string":
-			fieldValue = v.Field(i).String()
+			fieldValue = value.String()
 		case "int", "int8", "int16", "int32", "int64":
-			fieldValue = fmt.Sprint(v.Field(i).Int())
+			fieldValue = fmt.Sprint(value.Int())
 		case "uint", "uint8", "uint16", "uint32", "uint64":
-			fieldValue = fmt.Sprint(v.Field(i).Uint())
+			fieldValue = fmt.Sprint(value.Uint())
 		case "float64", "float32":
-			fieldValue = fmt.Sprint(v.Field(i).Float())
+			fieldValue = fmt.Sprint(value.Float())
 		case "bool":
-			fieldValue = fmt.Sprint(v.Field(i).Bool())
+			fieldValue = fmt.Sprint(value.Bool())
 		}
 
 		tags := strings.Split(t.Field(i).Tag.Get("requirements"), ";")
@@ -179,21 +205,55 @@ func GetRequirements(obj any) []requirement {
 	return requirements
 }
 
+// RequirementsError lists the requirements that a struct doesn't meet
+type RequirementsError struct {
+	// Fields maps the json, form or uri name of each invalid field to its error
+	Fields map[string]string
+	errors string
+}
+
+func (e *RequirementsError) Error() string {
+	return e.errors
+}
+
 func VerifyRequirements(obj any) error {
 	requirements := GetRequirements(obj)
 	var errors string
+	fields := make(map[string]string)
 	for _, r := range requirements {
 		err := r.Verify()
 		if err != nil {
 			errors += fmt.Sprint(err.Error()) + ";"
+
+			name := fieldKey(reflect.TypeOf(obj), r.fieldName)
+			if _, ok := fields[name]; !ok {
+				fields[name] = err.Error()
+			}
 		}
 	}
 
 	if errors == "" {
 		return nil
 	} else {
-		return fmt.Errorf(errors)
+		return &RequirementsError{Fields: fields, errors: errors}
+	}
+}
+
+// fieldKey returns the name that clients use for a struct field
+func fieldKey(t reflect.Type, fieldName string) string {
+	field, ok := t.FieldByName(fieldName)
+	if !ok {
+		return fieldName
+	}
+
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
 	}
+
+	return fieldName
 }
 
 func ShouldBindJSON(r *http.Request, obj any) error {
@@ -256,23 +316,36 @@ func ShouldBindUri(r *http.Request, obj any) error {
 }
 
 func ShouldBindQuery(r *http.Request, obj any) error {
+	// values are keyed by the struct field name so that json.Unmarshal
+	// matches them even when the form name is snake_case
 	query := make(map[string]any)
-
-	for k, v := range r.URL.Query() {
-		query[k] = v[0]
-	}
+	values := r.URL.Query()
 
 	t := reflect.TypeOf(obj).Elem()
 	for i := 0; i < t.NumField(); i++ {
 		form := t.Field(i).Tag.Get("form")
+		if !values.Has(form) {
+			continue
+		}
+
+		var err error
+		name := t.Field(i).Name
+		value := values.Get(form)
 		fieldType := t.Field(i).Type.String()
 		switch fieldType {
 		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
-			query[form], _ = strconv.Atoi(query[form].(string))
+			query[name], _ = strconv.Atoi(value)
 		case "float64", "float32":
-			query[form], _ = strconv.ParseFloat(query[form].(string), 64)
+			query[name], _ = strconv.ParseFloat(value, 64)
 		case "bool":
-			query[form] = query[form].(string)
+			query[name], err = strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("Query error: '%s' should be a boolean", form)
+			}
+		case "[]string":
+			query[name] = values[form]
+		default:
+			query[name] = value
 		}
 	}
 
@@ -1,8 +1,11 @@
 package util
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -227,6 +230,7 @@ func TestVerifyRequirements(t *testing.T) {
 		Age        int     `requirements:"required;min=18;max=60"`
 		Money      float64 `requirements:"required;min=100;max=1000"`
 		IsCriminal bool    `requirements:"required"`
+		Role       string  `requirements:"oneof=thief killer"`
 	}
 
 	type test struct {
@@ -265,6 +269,19 @@ func TestVerifyRequirements(t *testing.T) {
 			},
 			isErr: true,
 		},
+		{
+			name: "Verify OneOf",
+			request: testRequest{
+				Username:   "dmvnicolas",
+				Email:      "dmvnicolas@gmail.com",
+				Password:   "83nicomoreno19",
+				Age:        18,
+				Money:      525.5,
+				IsCriminal: true,
+				Role:       "doctor",
+			},
+			isErr: true,
+		},
 		{
 			name: "Verify Max",
 			request: testRequest{
@@ -286,3 +303,118 @@ func TestVerifyRequirements(t *testing.T) {
 		})
 	}
 }
+
+func TestRequirementsErrorFields(t *testing.T) {
+	type testRequest struct {
+		Username string `json:"username" requirements:"required"`
+		Password string `json:"password" requirements:"required;min=8"`
+		Limit    int32  `form:"limit" requirements:"min=1"`
+		Priority string `requirements:"oneof=low high"`
+	}
+
+	err := VerifyRequirements(testRequest{Username: "dmvnicolas", Priority: "medium"})
+	require.Error(t, err)
+
+	var reqErr *RequirementsError
+	require.ErrorAs(t, err, &reqErr)
+	require.Len(t, reqErr.Fields, 3)
+	require.Contains(t, reqErr.Fields["password"], "is required")
+	require.Contains(t, reqErr.Fields["limit"], "less than the minimum value")
+	require.Contains(t, reqErr.Fields["Priority"], "should be one of")
+	require.NotContains(t, reqErr.Fields, "username")
+}
+
+func TestVerifyOptionalRequirements(t *testing.T) {
+	type testRequest struct {
+		Email    *string `json:"email" requirements:"required;email"`
+		FullName *string `json:"full_name" requirements:"max=10"`
+	}
+
+	err := VerifyRequirements(testRequest{})
+	require.NoError(t, err)
+
+	email := "dmvnicolas@gmail.com"
+	err = VerifyRequirements(testRequest{Email: &email})
+	require.NoError(t, err)
+
+	invalidEmail := "dmvnicolas"
+	fullName := "Nicolas Moreno Bernal"
+	err = VerifyRequirements(testRequest{Email: &invalidEmail, FullName: &fullName})
+
+	var reqErr *RequirementsError
+	require.ErrorAs(t, err, &reqErr)
+	require.Len(t, reqErr.Fields, 2)
+	require.Contains(t, reqErr.Fields["email"], "is not an Email")
+	require.Contains(t, reqErr.Fields["full_name"], "greater than the maximum value")
+}
+
+func TestShouldBindQuery(t *testing.T) {
+	type testRequest struct {
+		Offset    int32      `form:"offset" requirements:"min=0"`
+		Limit     int32      `form:"limit" requirements:"min=1"`
+		DueBefore *time.Time `form:"due_before"`
+		Overdue   bool       `form:"overdue"`
+		Tags      []string   `form:"tag"`
+	}
+
+	dueBefore := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+	type test struct {
+		name    string
+		query   string
+		request testRequest
+		isErr   bool
+	}
+
+	tests := []test{
+		{
+			name:  "All fields",
+			query: "offset=5&limit=10&due_before=2024-01-02T15:04:05Z&overdue=true&tag=work&tag=urgent",
+			request: testRequest{
+				Offset:    5,
+				Limit:     10,
+				DueBefore: &dueBefore,
+				Overdue:   true,
+				Tags:      []string{"work", "urgent"},
+			},
+			isErr: false,
+		},
+		{
+			name:  "Missing optional fields",
+			query: "offset=0&limit=10",
+			request: testRequest{
+				Offset: 0,
+				Limit:  10,
+			},
+			isErr: false,
+		},
+		{
+			name:  "Invalid bool",
+			query: "offset=0&limit=10&overdue=maybe",
+			isErr: true,
+		},
+		{
+			name:  "Invalid time",
+			query: "offset=0&limit=10&due_before=tomorrow",
+			isErr: true,
+		},
+		{
+			name:  "Requirements",
+			query: "offset=0&limit=0",
+			isErr: true,
+		},
+	}
+
+	for _, x := range tests {
+		t.Run(x.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+x.query, nil)
+
+			var req testRequest
+			err := ShouldBindQuery(r, &req)
+			require.Equal(t, x.isErr, err != nil)
+			if !x.isErr {
+				require.Equal(t, x.request, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package util
+
+// Roles a user can have. Every user starts with UserRole, AdminRole gives
+// access to the admin api used by the support staff
+const (
+	UserRole  = "user"
+	AdminRole = "admin"
+)
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureToken(t *testing.T) {
+	token1, err := SecureToken(32)
+	require.NoError(t, err)
+
+	b, err := base64.RawURLEncoding.DecodeString(token1)
+	require.NoError(t, err)
+	require.Len(t, b, 32)
+
+	token2, err := SecureToken(32)
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+}
+
+func TestHashToken(t *testing.T) {
+	token, err := SecureToken(32)
+	require.NoError(t, err)
+
+	hash := HashToken(token)
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, HashToken(token))
+	require.NotEqual(t, hash, HashToken(token+"x"))
+	require.NotContains(t, hash, token)
+}
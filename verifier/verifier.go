@@ -0,0 +1,137 @@
+package verifier
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+)
+
+// KeysPath is the path where DevoraTasks publishes the public keys of its tokens
+const KeysPath = "/.well-known/paseto-keys"
+
+const (
+	keyVersion = "v4"
+	keyPurpose = "public"
+)
+
+// Key is a public key as published in the key set
+type Key struct {
+	KeyID     string `json:"kid"`
+	Version   string `json:"version"`
+	Purpose   string `json:"purpose"`
+	PublicKey string `json:"public_key"`
+}
+
+// KeySet is the body of the keys endpoint
+type KeySet struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKeySet encodes the public keys of a token maker
+func NewKeySet(keys []token.PublicKey) KeySet {
+	set := KeySet{Keys: make([]Key, len(keys))}
+	for i, key := range keys {
+		set.Keys[i] = Key{
+			KeyID:     key.ID,
+			Version:   keyVersion,
+			Purpose:   keyPurpose,
+			PublicKey: base64.RawURLEncoding.EncodeToString(key.Key),
+		}
+	}
+	return set
+}
+
+// PublicKeys decodes the keys of the set, failing if any of them is malformed
+// or its id doesn't belong to it
+func (set KeySet) PublicKeys() ([]token.PublicKey, error) {
+	keys := make([]token.PublicKey, len(set.Keys))
+	for i, key := range set.Keys {
+		if key.Version != keyVersion || key.Purpose != keyPurpose {
+			return nil, fmt.Errorf("key %s: unsupported version %s.%s", key.KeyID, key.Version, key.Purpose)
+		}
+
+		publicKey, err := base64.RawURLEncoding.DecodeString(key.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s: invalid public key", key.KeyID)
+		}
+
+		if token.KeyID(publicKey) != key.KeyID {
+			return nil, fmt.Errorf("key %s: the id doesn't match the public key", key.KeyID)
+		}
+
+		keys[i] = token.PublicKey{ID: key.KeyID, Key: publicKey}
+	}
+	return keys, nil
+}
+
+// FetchKeys downloads the public keys published by the DevoraTasks server at baseURL
+func FetchKeys(ctx context.Context, client *http.Client, baseURL string) ([]token.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+KeysPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch keys: unexpected status %s", res.Status)
+	}
+
+	var set KeySet
+	err = json.NewDecoder(res.Body).Decode(&set)
+	if err != nil {
+		return nil, fmt.Errorf("fetch keys: %w", err)
+	}
+
+	return set.PublicKeys()
+}
+
+// Verifier validates DevoraTasks access tokens offline with their public keys.
+// It can't know about revoked tokens, they stay valid until they expire
+type Verifier struct {
+	mu     sync.RWMutex
+	keys   []token.PublicKey
+	claims token.Claims
+}
+
+// New creates a verifier that trusts the given keys
+func New(keys ...token.PublicKey) *Verifier {
+	return &Verifier{keys: keys}
+}
+
+// RequireClaims makes the verifier reject the tokens that weren't issued by or
+// for the given claims, it must be called before the verifier is used
+func (verifier *Verifier) RequireClaims(claims token.Claims) {
+	verifier.claims = claims
+}
+
+// SetKeys replaces the trusted keys, it's meant to be called when the keys are
+// fetched again after a rotation
+func (verifier *Verifier) SetKeys(keys []token.PublicKey) {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+
+	verifier.keys = keys
+}
+
+// Verify checks the signature, the claims and the expiration of an access token
+// and returns its payload, refresh tokens are rejected. It fails with
+// token.ErrInvalidToken or token.ErrExpiredToken
+func (verifier *Verifier) Verify(tokenString string) (*token.Payload, error) {
+	verifier.mu.RLock()
+	keys := verifier.keys
+	verifier.mu.RUnlock()
+
+	return token.VerifyPublicToken(tokenString, token.TokenTypeAccessToken, keys, verifier.claims)
+}
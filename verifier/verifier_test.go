@@ -0,0 +1,150 @@
+package verifier
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMV-Nicolas/DevoraTasks/token"
+	"github.com/DMV-Nicolas/DevoraTasks/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomMaker(t *testing.T) token.Maker {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	maker, err := token.NewPublicPasetoMaker(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	return maker
+}
+
+func publicKeys(maker token.Maker) []token.PublicKey {
+	return maker.(token.KeyPublisher).PublicKeys()
+}
+
+func TestKeySet(t *testing.T) {
+	keys := publicKeys(randomMaker(t))
+
+	set := NewKeySet(keys)
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, keys[0].ID, set.Keys[0].KeyID)
+	require.Equal(t, "v4", set.Keys[0].Version)
+	require.Equal(t, "public", set.Keys[0].Purpose)
+
+	gotKeys, err := set.PublicKeys()
+	require.NoError(t, err)
+	require.Equal(t, keys, gotKeys)
+
+	otherKeys := publicKeys(randomMaker(t))
+
+	testCases := []struct {
+		name   string
+		modify func(key *Key)
+	}{
+		{name: "OtherVersion", modify: func(key *Key) { key.Version = "v2" }},
+		{name: "OtherPurpose", modify: func(key *Key) { key.Purpose = "local" }},
+		{name: "InvalidKey", modify: func(key *Key) { key.PublicKey = "invalid" }},
+		{name: "MismatchedID", modify: func(key *Key) { key.KeyID = otherKeys[0].ID }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			set := NewKeySet(keys)
+			tc.modify(&set.Keys[0])
+
+			_, err := set.PublicKeys()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFetchKeys(t *testing.T) {
+	keys := publicKeys(randomMaker(t))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != KeysPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(NewKeySet(keys))
+	}))
+	defer server.Close()
+
+	gotKeys, err := FetchKeys(context.Background(), server.Client(), server.URL+"/")
+	require.NoError(t, err)
+	require.Equal(t, keys, gotKeys)
+
+	_, err = FetchKeys(context.Background(), server.Client(), server.URL+"/missing")
+	require.Error(t, err)
+}
+
+func TestVerifier(t *testing.T) {
+	oldMaker := randomMaker(t)
+	newMaker := randomMaker(t)
+
+	oldToken, oldPayload, err := oldMaker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	newToken, newPayload, err := newMaker.CreateToken(util.RandomUsername(), util.AdminRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	verifier := New(publicKeys(oldMaker)...)
+
+	payload, err := verifier.Verify(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, oldPayload.ID, payload.ID)
+	require.Equal(t, oldPayload.Username, payload.Username)
+
+	_, err = verifier.Verify(newToken)
+	require.EqualError(t, err, token.ErrInvalidToken.Error())
+
+	// after the rotation both keys are published until the old tokens expire
+	verifier.SetKeys(append(publicKeys(oldMaker), publicKeys(newMaker)...))
+
+	payload, err = verifier.Verify(newToken)
+	require.NoError(t, err)
+	require.Equal(t, newPayload.ID, payload.ID)
+	require.Equal(t, util.AdminRole, payload.Role)
+
+	_, err = verifier.Verify(oldToken)
+	require.NoError(t, err)
+
+	expiredToken, _, err := newMaker.CreateToken(util.RandomUsername(), util.UserRole, -time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(expiredToken)
+	require.EqualError(t, err, token.ErrExpiredToken.Error())
+}
+
+func TestVerifierRequireClaims(t *testing.T) {
+	claims := token.Claims{Issuer: "devoratasks", Audience: "devoratasks-api"}
+	maker := randomMaker(t)
+	maker.(token.ClaimsSetter).SetClaims(claims)
+
+	tokenString, _, err := maker.CreateToken(util.RandomUsername(), util.UserRole, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	verifier := New(publicKeys(maker)...)
+	verifier.RequireClaims(claims)
+
+	payload, err := verifier.Verify(tokenString)
+	require.NoError(t, err)
+	require.Equal(t, claims.Issuer, payload.Issuer)
+	require.Equal(t, claims.Audience, payload.Audience)
+
+	// a service expecting tokens for another audience must reject it
+	verifier.RequireClaims(token.Claims{Audience: "another-api"})
+	_, err = verifier.Verify(tokenString)
+	require.EqualError(t, err, token.ErrInvalidToken.Error())
+}